@@ -0,0 +1,213 @@
+package apperror
+
+import (
+	"errors"
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/runtime/protoiface"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"time"
+)
+
+// The kinds of the domain errors, match them with errors.Is
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrInvalidArgument = errors.New("invalid argument")
+	ErrInvalidType     = errors.New("invalid type")
+	ErrTooLarge        = errors.New("too large")
+	ErrQuotaExceeded   = errors.New("quota exceeded")
+	ErrVersionMismatch = errors.New("version mismatch")
+	ErrPrecondition    = errors.New("failed precondition")
+	ErrPermission      = errors.New("permission denied")
+	ErrAborted         = errors.New("aborted")
+	ErrUnavailable     = errors.New("backend unavailable")
+	ErrInternal        = errors.New("internal")
+)
+
+type Backend string
+
+const (
+	Database Backend = "database"
+	Cache    Backend = "cache"
+	Storage  Backend = "storage"
+)
+
+const (
+	defaultRetryDelay = time.Second
+	errorDomain       = "rnkm65-file"
+)
+
+// Error is a domain error with the grpc code and the details sent to the client, the cause is only
+// kept for the logs and errors.Is, it is never sent to the client
+type Error struct {
+	kind    error
+	code    codes.Code
+	message string
+	details []protoiface.MessageV1
+	cause   error
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %v", e.message, e.cause)
+	}
+
+	return e.message
+}
+
+func (e *Error) Is(target error) bool {
+	return e.kind == target
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// GRPCStatus lets grpc and status.FromError turn the error into the status with its details
+func (e *Error) GRPCStatus() *status.Status {
+	st := status.New(e.code, e.message)
+
+	withDetails, err := st.WithDetails(e.details...)
+	if err != nil {
+		return st
+	}
+
+	return withDetails
+}
+
+// NotFound is the missing resource, the type is the kind of resource like file or link
+func NotFound(resourceType string, name string) *Error {
+	return &Error{
+		kind:    ErrNotFound,
+		code:    codes.NotFound,
+		message: "Not found " + resourceType,
+		details: []protoiface.MessageV1{&errdetails.ResourceInfo{
+			ResourceType: resourceType,
+			ResourceName: name,
+			Description:  "Not found " + resourceType,
+		}},
+	}
+}
+
+// InvalidArgument is a bad field of the request
+func InvalidArgument(field string, description string) *Error {
+	return badRequest(ErrInvalidArgument, field, description)
+}
+
+// InvalidType is a file type or a tag the service does not accept
+func InvalidType(field string, description string) *Error {
+	return badRequest(ErrInvalidType, field, description)
+}
+
+// TooLarge is a field over its limit, the size and the limit are in the unit of the field
+func TooLarge(field string, size int, limit int) *Error {
+	return badRequest(ErrTooLarge, field, fmt.Sprintf("%v is over the limit of %v", size, limit))
+}
+
+// QuotaExceeded is a used up limit of the subject, it does not reset by retrying
+func QuotaExceeded(subject string, description string) *Error {
+	return &Error{
+		kind:    ErrQuotaExceeded,
+		code:    codes.ResourceExhausted,
+		message: description,
+		details: []protoiface.MessageV1{&errdetails.QuotaFailure{
+			Violations: []*errdetails.QuotaFailure_Violation{{Subject: subject, Description: description}},
+		}},
+	}
+}
+
+// VersionMismatch is a write with the if match version that is not the current version of the resource,
+// the client has to read the resource again before retrying
+func VersionMismatch(resourceType string, name string) *Error {
+	return &Error{
+		kind:    ErrVersionMismatch,
+		code:    codes.FailedPrecondition,
+		message: "The " + resourceType + " was changed by another request",
+		details: []protoiface.MessageV1{&errdetails.PreconditionFailure{
+			Violations: []*errdetails.PreconditionFailure_Violation{{
+				Type:        "VERSION",
+				Subject:     resourceType + ":" + name,
+				Description: "The if match version is not the current version",
+			}},
+		}},
+	}
+}
+
+// FailedPrecondition is a request the resource is not in the state for, retrying does not help until it changes
+func FailedPrecondition(violationType string, subject string, description string) *Error {
+	return &Error{
+		kind:    ErrPrecondition,
+		code:    codes.FailedPrecondition,
+		message: description,
+		details: []protoiface.MessageV1{&errdetails.PreconditionFailure{
+			Violations: []*errdetails.PreconditionFailure_Violation{{
+				Type:        violationType,
+				Subject:     subject,
+				Description: description,
+			}},
+		}},
+	}
+}
+
+// PermissionDenied is a caller without the permission, the permission is the one it needed
+func PermissionDenied(permission string) *Error {
+	return &Error{
+		kind:    ErrPermission,
+		code:    codes.PermissionDenied,
+		message: "Permission denied",
+		details: []protoiface.MessageV1{&errdetails.ErrorInfo{
+			Reason:   "PERMISSION_DENIED",
+			Domain:   errorDomain,
+			Metadata: map[string]string{"permission": permission},
+		}},
+	}
+}
+
+// Aborted is a request that lost a race with a concurrent request, the client may retry the whole request
+// after the delay
+func Aborted(reason string, description string) *Error {
+	return &Error{
+		kind:    ErrAborted,
+		code:    codes.Aborted,
+		message: description,
+		details: []protoiface.MessageV1{
+			&errdetails.ErrorInfo{Reason: reason, Domain: errorDomain},
+			&errdetails.RetryInfo{RetryDelay: durationpb.New(defaultRetryDelay)},
+		},
+	}
+}
+
+// Unavailable is a failed call to the backend, the client may retry after the delay
+func Unavailable(backend Backend, cause error) *Error {
+	return &Error{
+		kind:    ErrUnavailable,
+		code:    codes.Unavailable,
+		message: fmt.Sprintf("The %v is unavailable", backend),
+		details: []protoiface.MessageV1{&errdetails.RetryInfo{RetryDelay: durationpb.New(defaultRetryDelay)}},
+		cause:   cause,
+	}
+}
+
+// Internal is a bug or a failure of the process itself, the cause is only kept for the logs
+func Internal(cause error) *Error {
+	return &Error{
+		kind:    ErrInternal,
+		code:    codes.Internal,
+		message: "Internal service error",
+		details: []protoiface.MessageV1{&errdetails.ErrorInfo{Reason: "INTERNAL", Domain: errorDomain}},
+		cause:   cause,
+	}
+}
+
+func badRequest(kind error, field string, description string) *Error {
+	return &Error{
+		kind:    kind,
+		code:    codes.InvalidArgument,
+		message: description,
+		details: []protoiface.MessageV1{&errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: field, Description: description}},
+		}},
+	}
+}
@@ -0,0 +1,194 @@
+package apperror
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+type AppErrorTest struct {
+	suite.Suite
+}
+
+func TestAppError(t *testing.T) {
+	suite.Run(t, new(AppErrorTest))
+}
+
+func (t *AppErrorTest) TestNotFound() {
+	err := NotFound("file", "owner-id")
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.True(t.T(), errors.Is(err, ErrNotFound))
+	assert.Equal(t.T(), codes.NotFound, st.Code())
+	assert.Equal(t.T(), "Not found file", st.Message())
+	assert.Len(t.T(), st.Details(), 1)
+
+	info, ok := st.Details()[0].(*errdetails.ResourceInfo)
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), "file", info.ResourceType)
+	assert.Equal(t.T(), "owner-id", info.ResourceName)
+}
+
+func (t *AppErrorTest) TestBadRequest() {
+	tests := []struct {
+		err         *Error
+		kind        error
+		field       string
+		description string
+	}{
+		{InvalidArgument("userId", "User id is required"), ErrInvalidArgument, "userId", "User id is required"},
+		{InvalidType("type", "Invalid file type"), ErrInvalidType, "type", "Invalid file type"},
+		{TooLarge("items", 101, 100), ErrTooLarge, "items", "101 is over the limit of 100"},
+	}
+
+	for _, test := range tests {
+		st, ok := status.FromError(test.err)
+
+		assert.True(t.T(), ok)
+		assert.True(t.T(), errors.Is(test.err, test.kind))
+		assert.Equal(t.T(), codes.InvalidArgument, st.Code())
+		assert.Equal(t.T(), test.description, st.Message())
+		assert.Len(t.T(), st.Details(), 1)
+
+		badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+
+		assert.True(t.T(), ok)
+		assert.Len(t.T(), badRequest.FieldViolations, 1)
+		assert.Equal(t.T(), test.field, badRequest.FieldViolations[0].Field)
+		assert.Equal(t.T(), test.description, badRequest.FieldViolations[0].Description)
+	}
+}
+
+func (t *AppErrorTest) TestQuotaExceeded() {
+	err := QuotaExceeded("link:id", "Download limit reached")
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.True(t.T(), errors.Is(err, ErrQuotaExceeded))
+	assert.Equal(t.T(), codes.ResourceExhausted, st.Code())
+	assert.Len(t.T(), st.Details(), 1)
+
+	quota, ok := st.Details()[0].(*errdetails.QuotaFailure)
+
+	assert.True(t.T(), ok)
+	assert.Len(t.T(), quota.Violations, 1)
+	assert.Equal(t.T(), "link:id", quota.Violations[0].Subject)
+}
+
+func (t *AppErrorTest) TestFailedPrecondition() {
+	err := FailedPrecondition("STATIC", "subscription:static:audit", "Cannot delete")
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.True(t.T(), errors.Is(err, ErrPrecondition))
+	assert.Equal(t.T(), codes.FailedPrecondition, st.Code())
+	assert.Equal(t.T(), "Cannot delete", st.Message())
+	assert.Len(t.T(), st.Details(), 1)
+
+	precondition, ok := st.Details()[0].(*errdetails.PreconditionFailure)
+
+	assert.True(t.T(), ok)
+	assert.Len(t.T(), precondition.Violations, 1)
+	assert.Equal(t.T(), "STATIC", precondition.Violations[0].Type)
+	assert.Equal(t.T(), "subscription:static:audit", precondition.Violations[0].Subject)
+}
+
+func (t *AppErrorTest) TestUnavailableHidesCause() {
+	cause := errors.New("dial tcp 10.0.0.1:3306: connection refused")
+	err := Unavailable(Database, cause)
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.True(t.T(), errors.Is(err, ErrUnavailable))
+	assert.True(t.T(), errors.Is(err, cause))
+	assert.Equal(t.T(), codes.Unavailable, st.Code())
+	assert.Equal(t.T(), "The database is unavailable", st.Message())
+	assert.Len(t.T(), st.Details(), 1)
+
+	retry, ok := st.Details()[0].(*errdetails.RetryInfo)
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), time.Second, retry.RetryDelay.AsDuration())
+}
+
+func (t *AppErrorTest) TestVersionMismatch() {
+	err := VersionMismatch("file", "owner-id")
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.True(t.T(), errors.Is(err, ErrVersionMismatch))
+	assert.Equal(t.T(), codes.FailedPrecondition, st.Code())
+	assert.Len(t.T(), st.Details(), 1)
+
+	precondition, ok := st.Details()[0].(*errdetails.PreconditionFailure)
+
+	assert.True(t.T(), ok)
+	assert.Len(t.T(), precondition.Violations, 1)
+	assert.Equal(t.T(), "VERSION", precondition.Violations[0].Type)
+	assert.Equal(t.T(), "file:owner-id", precondition.Violations[0].Subject)
+}
+
+func (t *AppErrorTest) TestPermissionDenied() {
+	err := PermissionDenied("read")
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.True(t.T(), errors.Is(err, ErrPermission))
+	assert.Equal(t.T(), codes.PermissionDenied, st.Code())
+	assert.Equal(t.T(), "Permission denied", st.Message())
+	assert.Len(t.T(), st.Details(), 1)
+
+	info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), "PERMISSION_DENIED", info.Reason)
+	assert.Equal(t.T(), "read", info.Metadata["permission"])
+}
+
+func (t *AppErrorTest) TestAborted() {
+	err := Aborted("FILE_REPLACED", "The file was replaced while deleting")
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.True(t.T(), errors.Is(err, ErrAborted))
+	assert.Equal(t.T(), codes.Aborted, st.Code())
+	assert.Equal(t.T(), "The file was replaced while deleting", st.Message())
+	assert.Len(t.T(), st.Details(), 2)
+
+	info, ok := st.Details()[0].(*errdetails.ErrorInfo)
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), "FILE_REPLACED", info.Reason)
+
+	_, ok = st.Details()[1].(*errdetails.RetryInfo)
+
+	assert.True(t.T(), ok)
+}
+
+func (t *AppErrorTest) TestInternalHidesCause() {
+	cause := errors.New("crypto/rand: read failed")
+	err := Internal(cause)
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.True(t.T(), errors.Is(err, ErrInternal))
+	assert.True(t.T(), errors.Is(err, cause))
+	assert.Equal(t.T(), codes.Internal, st.Code())
+	assert.Equal(t.T(), "Internal service error", st.Message())
+	assert.Len(t.T(), st.Details(), 1)
+}
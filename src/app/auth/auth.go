@@ -0,0 +1,53 @@
+package auth
+
+import "context"
+
+type Permission string
+
+const (
+	Read  Permission = "read"
+	Write Permission = "write"
+	Admin Permission = "admin"
+)
+
+// Caller is the identity of the rpc caller, Service is set when the calling service is authenticated
+// and Subject is the end user the call is made for, the trusted services may name the user in the request
+type Caller struct {
+	Service     string
+	Permissions []Permission
+	Trusted     bool
+	Subject     string
+	Roles       []string
+	Groups      []string
+	IsAdmin     bool
+}
+
+type callerKey struct{}
+
+func NewContext(ctx context.Context, caller *Caller) context.Context {
+	return context.WithValue(ctx, callerKey{}, caller)
+}
+
+func FromContext(ctx context.Context) (*Caller, bool) {
+	caller, ok := ctx.Value(callerKey{}).(*Caller)
+	return caller, ok
+}
+
+// Can reports whether the caller has the permission on the files of every user
+func (c *Caller) Can(permission Permission) bool {
+	if c.IsAdmin {
+		return true
+	}
+
+	for _, p := range c.Permissions {
+		if p == permission || p == Admin {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Caller) IsOwner(ownerID string) bool {
+	return c.Subject != "" && c.Subject == ownerID
+}
@@ -0,0 +1,12 @@
+package audit
+
+import "time"
+
+// Filter narrows the audit log, the empty fields and zero times are not filtered on
+type Filter struct {
+	UserID string
+	FileID string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
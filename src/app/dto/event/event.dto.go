@@ -0,0 +1,23 @@
+package event
+
+import "time"
+
+// File is the payload of the file events, it is the state of the file after the change
+type File struct {
+	ID       string `json:"id"`
+	OwnerID  string `json:"owner_id"`
+	Filename string `json:"filename"`
+	Tag      int    `json:"tag"`
+	Type     int    `json:"type"`
+	Public   bool   `json:"public"`
+	Version  int64  `json:"version"`
+}
+
+// Message is the event as it is published to the bus, Key is the id of the changed file
+type Message struct {
+	ID         string    `json:"id"`
+	Type       string    `json:"type"`
+	Key        string    `json:"key"`
+	Payload    []byte    `json:"payload"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
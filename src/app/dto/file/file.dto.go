@@ -1,6 +1,48 @@
 package file
 
+import (
+	"github.com/isd-sgcu/rnkm65-file/src/app/auth"
+	"io"
+	"time"
+)
+
 type CacheFile struct {
-	Url      string `json:"url"`
-	Filename string `json:"filename"`
+	ID        string    `json:"id,omitempty"`
+	Url       string    `json:"url"`
+	Filename  string    `json:"filename"`
+	Tag       int       `json:"tag"`
+	ExpiresAt time.Time `json:"expires_at"`
+	NotFound  bool      `json:"not_found,omitempty"`
+	Public    bool      `json:"public,omitempty"`
+	Version   int64     `json:"version,omitempty"`
+}
+
+// IdempotentUpload is the uploaded file kept under its idempotency key, the url is signed again on every replay
+// and the fingerprint tells a retry of the same upload from a key reused for another one
+type IdempotentUpload struct {
+	Fingerprint string `json:"fingerprint"`
+	ID          string `json:"id"`
+	Version     int64  `json:"version"`
+	Filename    string `json:"filename"`
+	Tag         int    `json:"tag"`
+	Public      bool   `json:"public,omitempty"`
+}
+
+// DownloadToken is what the download proxy token stands for, the caller is authorized again on every download
+// and the version tells the file the token was issued for from the one that replaced it under the same id
+type DownloadToken struct {
+	OwnerID string       `json:"owner_id"`
+	FileID  string       `json:"file_id"`
+	Version int64        `json:"version,omitempty"`
+	Caller  *auth.Caller `json:"caller,omitempty"`
+}
+
+// Object is a readable object from the storage, seeking reopens the range from the storage
+type Object struct {
+	io.ReadSeekCloser
+	Name        string
+	ContentType string
+	ETag        string
+	Size        int64
+	UpdatedAt   time.Time
 }
@@ -0,0 +1,17 @@
+package health
+
+import "time"
+
+// Report is the readiness of the service with the last probe of each dependency, it has no error
+// messages since it is served on the public http port, the errors are in the logs
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]*Check `json:"checks"`
+}
+
+type Check struct {
+	Status    string    `json:"status"`
+	Latency   string    `json:"latency"`
+	Failures  int       `json:"consecutive_failures"`
+	CheckedAt time.Time `json:"checked_at"`
+}
@@ -0,0 +1,84 @@
+package download
+
+import (
+	"context"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+type Handler struct {
+	prefix  string
+	service IService
+}
+
+type IService interface {
+	OpenDownload(context.Context, string) (*dto.Object, error)
+}
+
+// NewHandler serves the download proxy mounted at the prefix, the rest of the path is the token,
+// the token is checked on every request so a revoked access stops the download urls at once
+func NewHandler(prefix string, service IService) *Handler {
+	return &Handler{
+		prefix:  prefix,
+		service: service,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimPrefix(r.URL.Path, h.prefix)
+	if token == "" || strings.Contains(token, "/") {
+		http.Error(w, "Not found download", http.StatusNotFound)
+		return
+	}
+
+	obj, err := h.service.OpenDownload(r.Context(), token)
+	if err != nil {
+		st := status.Convert(err)
+		http.Error(w, st.Message(), httpStatus(st.Code()))
+		return
+	}
+	defer obj.Close()
+
+	disposition := "inline"
+	if _, ok := r.URL.Query()["download"]; ok {
+		disposition = "attachment"
+	}
+
+	if obj.ContentType != "" {
+		w.Header().Set("Content-Type", obj.ContentType)
+	}
+	if obj.ETag != "" {
+		w.Header().Set("ETag", `"`+strings.Trim(obj.ETag, `"`)+`"`)
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": obj.Name}))
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	// ServeContent handles the range and the conditional requests with the etag set above
+	http.ServeContent(w, r, obj.Name, obj.UpdatedAt, obj)
+}
+
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,138 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type serviceMock struct {
+	mock.Mock
+}
+
+func (s *serviceMock) OpenDownload(_ context.Context, token string) (*dto.Object, error) {
+	args := s.Called(token)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*dto.Object), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+type DownloadHandlerTest struct {
+	suite.Suite
+	content []byte
+}
+
+func TestDownloadHandler(t *testing.T) {
+	suite.Run(t, new(DownloadHandlerTest))
+}
+
+func (t *DownloadHandlerTest) SetupTest() {
+	t.content = []byte("Hello, world")
+}
+
+func (t *DownloadHandlerTest) object() *dto.Object {
+	return &dto.Object{
+		ReadSeekCloser: nopCloser{bytes.NewReader(t.content)},
+		Name:           "hello world.txt",
+		ContentType:    "text/plain",
+		ETag:           "abc",
+		Size:           int64(len(t.content)),
+		UpdatedAt:      time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func (t *DownloadHandlerTest) serve(r *http.Request) *httptest.ResponseRecorder {
+	srv := &serviceMock{}
+	srv.On("OpenDownload", "token").Return(t.object(), nil)
+
+	w := httptest.NewRecorder()
+	NewHandler("/downloads/", srv).ServeHTTP(w, r)
+
+	return w
+}
+
+func (t *DownloadHandlerTest) TestDownload() {
+	w := t.serve(httptest.NewRequest(http.MethodGet, "/downloads/token", nil))
+
+	assert.Equal(t.T(), http.StatusOK, w.Code)
+	assert.Equal(t.T(), t.content, w.Body.Bytes())
+	assert.Equal(t.T(), "text/plain", w.Header().Get("Content-Type"))
+	assert.Equal(t.T(), `"abc"`, w.Header().Get("ETag"))
+	assert.Equal(t.T(), "bytes", w.Header().Get("Accept-Ranges"))
+	assert.Equal(t.T(), `inline; filename="hello world.txt"`, w.Header().Get("Content-Disposition"))
+}
+
+func (t *DownloadHandlerTest) TestDownloadAttachment() {
+	w := t.serve(httptest.NewRequest(http.MethodGet, "/downloads/token?download", nil))
+
+	assert.Equal(t.T(), http.StatusOK, w.Code)
+	assert.Equal(t.T(), `attachment; filename="hello world.txt"`, w.Header().Get("Content-Disposition"))
+}
+
+func (t *DownloadHandlerTest) TestDownloadRange() {
+	r := httptest.NewRequest(http.MethodGet, "/downloads/token", nil)
+	r.Header.Set("Range", "bytes=7-")
+
+	w := t.serve(r)
+
+	assert.Equal(t.T(), http.StatusPartialContent, w.Code)
+	assert.Equal(t.T(), "world", w.Body.String())
+	assert.Equal(t.T(), "bytes 7-11/12", w.Header().Get("Content-Range"))
+}
+
+func (t *DownloadHandlerTest) TestDownloadNotModified() {
+	r := httptest.NewRequest(http.MethodGet, "/downloads/token", nil)
+	r.Header.Set("If-None-Match", `"abc"`)
+
+	w := t.serve(r)
+
+	assert.Equal(t.T(), http.StatusNotModified, w.Code)
+	assert.Empty(t.T(), w.Body.Bytes())
+}
+
+func (t *DownloadHandlerTest) TestErrorStatus() {
+	for code, want := range map[codes.Code]int{
+		codes.NotFound:         http.StatusNotFound,
+		codes.PermissionDenied: http.StatusForbidden,
+		codes.Unavailable:      http.StatusServiceUnavailable,
+	} {
+		srv := &serviceMock{}
+		srv.On("OpenDownload", "token").Return(nil, status.Error(code, code.String()))
+
+		w := httptest.NewRecorder()
+		NewHandler("/downloads/", srv).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/downloads/token", nil))
+
+		assert.Equal(t.T(), want, w.Code)
+	}
+}
+
+func (t *DownloadHandlerTest) TestMethodNotAllowed() {
+	srv := &serviceMock{}
+
+	w := httptest.NewRecorder()
+	NewHandler("/downloads/", srv).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/downloads/token", nil))
+
+	assert.Equal(t.T(), http.StatusMethodNotAllowed, w.Code)
+	srv.AssertNotCalled(t.T(), "OpenDownload", mock.Anything)
+}
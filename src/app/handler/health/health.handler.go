@@ -0,0 +1,43 @@
+package health
+
+import (
+	"encoding/json"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/health"
+	"net/http"
+)
+
+type Handler struct {
+	service IService
+}
+
+type IService interface {
+	Report() (*dto.Report, bool)
+}
+
+func NewHandler(service IService) *Handler {
+	return &Handler{service: service}
+}
+
+// Live reports that the process can serve http, it does not look at the dependencies so a database
+// outage does not get the pods restarted
+func (h *Handler) Live(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+// Ready reports the last probe of each dependency, it is 503 until every dependency is up
+func (h *Handler) Ready(w http.ResponseWriter, _ *http.Request) {
+	report, ok := h.service.Report()
+
+	code := http.StatusOK
+	if !ok {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(report)
+}
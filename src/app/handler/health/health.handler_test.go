@@ -0,0 +1,68 @@
+package health
+
+import (
+	"encoding/json"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type serviceMock struct {
+	report *dto.Report
+	ok     bool
+}
+
+func (s *serviceMock) Report() (*dto.Report, bool) {
+	return s.report, s.ok
+}
+
+type HealthHandlerTest struct {
+	suite.Suite
+	report *dto.Report
+}
+
+func TestHealthHandler(t *testing.T) {
+	suite.Run(t, new(HealthHandlerTest))
+}
+
+func (t *HealthHandlerTest) SetupTest() {
+	t.report = &dto.Report{
+		Status: "unavailable",
+		Checks: map[string]*dto.Check{
+			"database": {Status: "ok", Latency: "1ms"},
+			"redis":    {Status: "unavailable", Latency: "3s", Failures: 2},
+		},
+	}
+}
+
+func (t *HealthHandlerTest) TestLive() {
+	w := httptest.NewRecorder()
+	NewHandler(&serviceMock{report: t.report}).Live(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+
+	assert.Equal(t.T(), http.StatusOK, w.Code)
+}
+
+func (t *HealthHandlerTest) TestReadyUnavailable() {
+	w := httptest.NewRecorder()
+	NewHandler(&serviceMock{report: t.report}).Ready(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	actual := &dto.Report{}
+
+	assert.Equal(t.T(), http.StatusServiceUnavailable, w.Code)
+	assert.Nil(t.T(), json.Unmarshal(w.Body.Bytes(), actual))
+	assert.Equal(t.T(), "unavailable", actual.Checks["redis"].Status)
+	assert.Equal(t.T(), 2, actual.Checks["redis"].Failures)
+}
+
+func (t *HealthHandlerTest) TestReady() {
+	t.report.Status = "ok"
+
+	w := httptest.NewRecorder()
+	NewHandler(&serviceMock{report: t.report, ok: true}).Ready(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	assert.Equal(t.T(), http.StatusOK, w.Code)
+	assert.Equal(t.T(), "application/json", w.Header().Get("Content-Type"))
+}
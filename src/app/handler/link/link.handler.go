@@ -0,0 +1,64 @@
+package link
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"strings"
+)
+
+type Handler struct {
+	prefix  string
+	service IService
+}
+
+type IService interface {
+	ResolveShareLink(context.Context, string) (string, error)
+}
+
+// NewHandler serves the share links mounted at the prefix, the rest of the path is the token
+func NewHandler(prefix string, service IService) *Handler {
+	return &Handler{
+		prefix:  prefix,
+		service: service,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := strings.TrimPrefix(r.URL.Path, h.prefix)
+	if token == "" || strings.Contains(token, "/") {
+		http.Error(w, "Not found link", http.StatusNotFound)
+		return
+	}
+
+	url, err := h.service.ResolveShareLink(r.Context(), token)
+	if err != nil {
+		st := status.Convert(err)
+		http.Error(w, st.Message(), httpStatus(st.Code()))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+func httpStatus(code codes.Code) int {
+	switch code {
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.FailedPrecondition, codes.ResourceExhausted:
+		return http.StatusGone
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,84 @@
+package link
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type serviceMock struct {
+	mock.Mock
+}
+
+func (s *serviceMock) ResolveShareLink(_ context.Context, token string) (string, error) {
+	args := s.Called(token)
+
+	return args.String(0), args.Error(1)
+}
+
+type LinkHandlerTest struct {
+	suite.Suite
+	url string
+}
+
+func TestLinkHandler(t *testing.T) {
+	suite.Run(t, new(LinkHandlerTest))
+}
+
+func (t *LinkHandlerTest) SetupTest() {
+	t.url = "https://storage.googleapis.com/bucket/file?X-Goog-Signature=abc"
+}
+
+func (t *LinkHandlerTest) TestRedirect() {
+	srv := &serviceMock{}
+	srv.On("ResolveShareLink", "token").Return(t.url, nil)
+
+	w := httptest.NewRecorder()
+	NewHandler("/links/", srv).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/links/token", nil))
+
+	assert.Equal(t.T(), http.StatusFound, w.Code)
+	assert.Equal(t.T(), t.url, w.Header().Get("Location"))
+	assert.Equal(t.T(), "no-store", w.Header().Get("Cache-Control"))
+}
+
+func (t *LinkHandlerTest) TestErrorStatus() {
+	for code, want := range map[codes.Code]int{
+		codes.NotFound:           http.StatusNotFound,
+		codes.FailedPrecondition: http.StatusGone,
+		codes.ResourceExhausted:  http.StatusGone,
+		codes.Unavailable:        http.StatusServiceUnavailable,
+	} {
+		srv := &serviceMock{}
+		srv.On("ResolveShareLink", "token").Return("", status.Error(code, code.String()))
+
+		w := httptest.NewRecorder()
+		NewHandler("/links/", srv).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/links/token", nil))
+
+		assert.Equal(t.T(), want, w.Code)
+	}
+}
+
+func (t *LinkHandlerTest) TestMethodNotAllowed() {
+	srv := &serviceMock{}
+
+	w := httptest.NewRecorder()
+	NewHandler("/links/", srv).ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/links/token", nil))
+
+	assert.Equal(t.T(), http.StatusMethodNotAllowed, w.Code)
+	srv.AssertNotCalled(t.T(), "ResolveShareLink")
+}
+
+func (t *LinkHandlerTest) TestMissingToken() {
+	srv := &serviceMock{}
+
+	w := httptest.NewRecorder()
+	NewHandler("/links/", srv).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/links/", nil))
+
+	assert.Equal(t.T(), http.StatusNotFound, w.Code)
+}
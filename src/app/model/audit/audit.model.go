@@ -0,0 +1,26 @@
+package audit
+
+import (
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// Audit is one access to the files of a user, the table is append only so it has no update or delete time,
+// UserID is the owner of the file and Service with Subject is the caller
+type Audit struct {
+	ID        uuid.UUID `json:"id" gorm:"primary_key"`
+	Action    string    `json:"action"`
+	Service   string    `json:"service"`
+	Subject   string    `json:"subject" gorm:"index"`
+	UserID    string    `json:"user_id" gorm:"index:idx_audit_user,priority:1"`
+	FileID    string    `json:"file_id" gorm:"index:idx_audit_file,priority:1"`
+	Code      string    `json:"code"`
+	CreatedAt time.Time `json:"created_at" gorm:"type:datetime;autoCreateTime:nano;index;index:idx_audit_user,priority:2;index:idx_audit_file,priority:2"`
+}
+
+func (a *Audit) BeforeCreate(_ *gorm.DB) error {
+	a.ID = uuid.New()
+
+	return nil
+}
@@ -7,6 +7,10 @@ import (
 type File struct {
 	model.Base
 	Filename string `json:"filename" gorm:"index"`
+	Name     string `json:"name"`
 	OwnerID  string `json:"owner_id" gorm:"index:,unique"`
 	Tag      int    `json:"tag"`
+	Type     int    `json:"type"`
+	Public   bool   `json:"public"`
+	Version  int64  `json:"version" gorm:"not null;default:1"`
 }
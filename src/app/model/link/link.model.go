@@ -0,0 +1,19 @@
+package link
+
+import (
+	"github.com/google/uuid"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model"
+	"time"
+)
+
+// Link lets anyone holding the token download the file until it expires or runs out of downloads,
+// only the hash of the token is stored and revoking soft deletes the link
+type Link struct {
+	model.Base
+	FileID       uuid.UUID `json:"file_id" gorm:"index"`
+	OwnerID      string    `json:"owner_id" gorm:"index"`
+	TokenHash    string    `json:"-" gorm:"size:64;uniqueIndex"`
+	ExpiresAt    time.Time `json:"expires_at" gorm:"type:datetime"`
+	MaxDownloads int       `json:"max_downloads"`
+	CreatedBy    string    `json:"created_by"`
+}
@@ -0,0 +1,19 @@
+package outbox
+
+import "time"
+
+// Event is written in the same transaction as the change it describes, the relay claims it until ClaimedUntil,
+// publishes it to the bus and sets PublishedAt, the id is kept on the bus so the consumers can drop the redelivered ones
+type Event struct {
+	ID           uint64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Type         string     `json:"type"`
+	AggregateID  string     `json:"aggregate_id" gorm:"index"`
+	Payload      string     `json:"payload" gorm:"type:text"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"type:datetime;autoCreateTime:nano"`
+	ClaimedUntil *time.Time `json:"claimed_until" gorm:"type:datetime"`
+	PublishedAt  *time.Time `json:"published_at" gorm:"type:datetime;index"`
+}
+
+func (Event) TableName() string {
+	return "outbox"
+}
@@ -0,0 +1,16 @@
+package share
+
+import (
+	"github.com/isd-sgcu/rnkm65-file/src/app/model"
+	"time"
+)
+
+// Share grants a user or a group read access to the file of the owner until the expiry, no expiry never expires
+type Share struct {
+	model.Base
+	OwnerID     string     `json:"owner_id" gorm:"size:191;uniqueIndex:idx_share_grantee"`
+	GranteeType string     `json:"grantee_type" gorm:"size:191;uniqueIndex:idx_share_grantee;index:idx_share_lookup"`
+	GranteeID   string     `json:"grantee_id" gorm:"size:191;uniqueIndex:idx_share_grantee;index:idx_share_lookup"`
+	ExpiresAt   *time.Time `json:"expires_at" gorm:"type:datetime"`
+	CreatedBy   string     `json:"created_by"`
+}
@@ -0,0 +1,32 @@
+package webhook
+
+import (
+	"github.com/isd-sgcu/rnkm65-file/src/app/model"
+	"time"
+)
+
+// Subscription receives the events listed in Events, a comma separated list where empty means every event,
+// the secret signs the payloads so it is never returned after the subscription is created
+type Subscription struct {
+	model.Base
+	Url       string `json:"url"`
+	Secret    string `json:"-"`
+	Events    string `json:"events"`
+	CreatedBy string `json:"created_by"`
+}
+
+// Delivery is one event to be posted to one subscription, it is retried until it is delivered
+// or runs out of attempts and is left dead for the dead letter view
+type Delivery struct {
+	model.Base
+	SubscriptionID string     `json:"subscription_id" gorm:"size:191;uniqueIndex:idx_delivery_event,priority:1"`
+	EventID        string     `json:"event_id" gorm:"size:191;uniqueIndex:idx_delivery_event,priority:2"`
+	EventType      string     `json:"event_type"`
+	Payload        string     `json:"payload" gorm:"type:text"`
+	Status         string     `json:"status" gorm:"index:idx_delivery_due,priority:1"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"type:datetime;index:idx_delivery_due,priority:2"`
+	LastStatusCode int        `json:"last_status_code"`
+	LastError      string     `json:"last_error"`
+	DeliveredAt    *time.Time `json:"delivered_at" gorm:"type:datetime"`
+}
@@ -0,0 +1,48 @@
+package audit
+
+import (
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/audit"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/audit"
+	"gorm.io/gorm"
+)
+
+// Repository only appends and reads the audit log, the entries are never updated or deleted
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) Create(result *audit.Audit) error {
+	return r.db.Create(&result).Error
+}
+
+// CreateMany inserts the entries in one statement
+func (r *Repository) CreateMany(entries []*audit.Audit) error {
+	return r.db.Create(&entries).Error
+}
+
+// Find returns the newest entries first
+func (r *Repository) Find(filter *dto.Filter, result *[]audit.Audit) error {
+	query := r.db
+
+	if filter.UserID != "" {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+
+	if filter.FileID != "" {
+		query = query.Where("file_id = ?", filter.FileID)
+	}
+
+	if !filter.Since.IsZero() {
+		query = query.Where("created_at >= ?", filter.Since)
+	}
+
+	if !filter.Until.IsZero() {
+		query = query.Where("created_at < ?", filter.Until)
+	}
+
+	return query.Order("created_at desc").Limit(filter.Limit).Find(&result).Error
+}
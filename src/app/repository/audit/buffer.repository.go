@@ -0,0 +1,158 @@
+package audit
+
+import (
+	"errors"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/audit"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/audit"
+	"github.com/isd-sgcu/rnkm65-file/src/app/utils"
+	"github.com/isd-sgcu/rnkm65-file/src/config"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+const (
+	defaultBufferSize    = 10000
+	defaultBatchSize     = 100
+	defaultFlushInterval = time.Second
+)
+
+// ErrBufferFull is returned when the queue is full, the caller keeps the entry in the logs
+var ErrBufferFull = errors.New("audit buffer is full")
+
+// BufferedRepository queues the entries in memory and inserts them in batches so recording an access does not add
+// a database write to the request, the queue is bounded and a full queue rejects the entry. Stop writes the entries
+// still queued, the entries queued by a process that crashed are only in memory and are lost
+type BufferedRepository struct {
+	repository bufferedRepository
+	batchSize  int
+	interval   time.Duration
+	entries    chan *audit.Audit
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+type bufferedRepository interface {
+	CreateMany([]*audit.Audit) error
+	Find(*dto.Filter, *[]audit.Audit) error
+}
+
+func NewBufferedRepository(repository bufferedRepository, conf config.Audit) *BufferedRepository {
+	bufferSize := defaultBufferSize
+	if conf.BufferSize > 0 {
+		bufferSize = conf.BufferSize
+	}
+
+	batchSize := defaultBatchSize
+	if conf.BatchSize > 0 {
+		batchSize = conf.BatchSize
+	}
+
+	interval := defaultFlushInterval
+	if conf.FlushInterval > 0 {
+		interval = time.Duration(conf.FlushInterval) * time.Millisecond
+	}
+
+	return &BufferedRepository{
+		repository: repository,
+		batchSize:  batchSize,
+		interval:   interval,
+		entries:    make(chan *audit.Audit, bufferSize),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Start inserts the queued entries every interval or once a batch is full until Stop is called
+func (r *BufferedRepository) Start() {
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		batch := make([]*audit.Audit, 0, r.batchSize)
+		for {
+			select {
+			case <-r.stop:
+				r.drain(batch)
+				return
+			case entry := <-r.entries:
+				batch = append(batch, entry)
+				if len(batch) >= r.batchSize {
+					batch = r.flush(batch)
+				}
+			case <-ticker.C:
+				batch = r.flush(batch)
+			}
+		}
+	}()
+}
+
+// Stop writes the entries still queued, it has to be called once the requests are drained
+func (r *BufferedRepository) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+// Create queues the entry, it does not wait for the database
+func (r *BufferedRepository) Create(entry *audit.Audit) error {
+	select {
+	case r.entries <- entry:
+		return nil
+	default:
+		return ErrBufferFull
+	}
+}
+
+// Find reads the database, the entries still queued are not returned
+func (r *BufferedRepository) Find(filter *dto.Filter, result *[]audit.Audit) error {
+	return r.repository.Find(filter, result)
+}
+
+func (r *BufferedRepository) drain(batch []*audit.Audit) {
+	for {
+		select {
+		case entry := <-r.entries:
+			batch = append(batch, entry)
+			if len(batch) >= r.batchSize {
+				batch = r.flush(batch)
+			}
+		default:
+			r.flush(batch)
+			return
+		}
+	}
+}
+
+// flush inserts the batch and returns it emptied, a failed batch is logged entry by entry so it can still be
+// recovered from the logs
+func (r *BufferedRepository) flush(batch []*audit.Audit) []*audit.Audit {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := r.insert(batch); err != nil {
+		for _, entry := range batch {
+			log.Error().
+				Err(err).
+				Str("module", "audit").
+				Str("action", entry.Action).
+				Str("service", entry.Service).
+				Str("subject", entry.Subject).
+				Str("user_id", entry.UserID).
+				Str("file_id", entry.FileID).
+				Str("code", entry.Code).
+				Msg("Error while saving audit data")
+		}
+	}
+
+	return batch[:0]
+}
+
+// insert writes one batch, a panic is logged and the batch is reported as failed
+func (r *BufferedRepository) insert(batch []*audit.Audit) (err error) {
+	err = errors.New("audit insert panicked")
+	defer utils.Recover("audit writer")
+
+	return r.repository.CreateMany(batch)
+}
@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"errors"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/audit"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/audit"
+	"github.com/isd-sgcu/rnkm65-file/src/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"sync"
+	"testing"
+)
+
+type repositoryMock struct {
+	mu      sync.Mutex
+	batches [][]string
+	err     error
+}
+
+func (r *repositoryMock) CreateMany(entries []*audit.Audit) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.FileID
+	}
+	r.batches = append(r.batches, ids)
+
+	return r.err
+}
+
+func (r *repositoryMock) Find(*dto.Filter, *[]audit.Audit) error {
+	return nil
+}
+
+type BufferedRepositoryTest struct {
+	suite.Suite
+	repository *repositoryMock
+}
+
+func TestBufferedRepository(t *testing.T) {
+	suite.Run(t, new(BufferedRepositoryTest))
+}
+
+func (t *BufferedRepositoryTest) SetupTest() {
+	t.repository = &repositoryMock{}
+}
+
+func (t *BufferedRepositoryTest) TestStopWritesQueuedEntries() {
+	repo := NewBufferedRepository(t.repository, config.Audit{BatchSize: 2, FlushInterval: 60000})
+	repo.Start()
+
+	for _, id := range []string{"a", "b", "c"} {
+		assert.Nil(t.T(), repo.Create(&audit.Audit{FileID: id}))
+	}
+
+	repo.Stop()
+
+	assert.Equal(t.T(), [][]string{{"a", "b"}, {"c"}}, t.repository.batches)
+}
+
+func (t *BufferedRepositoryTest) TestFullBuffer() {
+	repo := NewBufferedRepository(t.repository, config.Audit{BufferSize: 1})
+
+	assert.Nil(t.T(), repo.Create(&audit.Audit{FileID: "a"}))
+	assert.Equal(t.T(), ErrBufferFull, repo.Create(&audit.Audit{FileID: "b"}))
+}
+
+func (t *BufferedRepositoryTest) TestFailedBatchDoesNotStop() {
+	t.repository.err = errors.New("Cannot connect to database")
+
+	repo := NewBufferedRepository(t.repository, config.Audit{BatchSize: 1, FlushInterval: 60000})
+	repo.Start()
+
+	assert.Nil(t.T(), repo.Create(&audit.Audit{FileID: "a"}))
+	assert.Nil(t.T(), repo.Create(&audit.Audit{FileID: "b"}))
+
+	repo.Stop()
+
+	assert.Equal(t.T(), [][]string{{"a"}, {"b"}}, t.repository.batches)
+}
@@ -4,9 +4,36 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
 	"time"
 )
 
+const scanBatchSize = 500
+
+// incrementScript sets the ttl in the same call as the increment that creates the counter
+var incrementScript = redis.NewScript(`
+local n = redis.call("INCR", KEYS[1])
+if n == 1 and tonumber(ARGV[1]) > 0 then
+	redis.call("EXPIRE", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// releaseScript deletes the lock only while it still holds the token of the caller
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+var tracer = otel.Tracer("github.com/isd-sgcu/rnkm65-file/src/app/repository/cache")
+
 type Repository struct {
 	client *redis.Client
 }
@@ -15,8 +42,11 @@ func NewRepository(client *redis.Client) *Repository {
 	return &Repository{client: client}
 }
 
-func (r *Repository) SaveCache(key string, value interface{}, ttl int) (err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (r *Repository) SaveCache(ctx context.Context, key string, value interface{}, ttl int) (err error) {
+	ctx, span := startSpan(ctx, "SaveCache")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	v, err := json.Marshal(value)
@@ -27,8 +57,11 @@ func (r *Repository) SaveCache(key string, value interface{}, ttl int) (err erro
 	return r.client.Set(ctx, key, v, time.Duration(ttl)*time.Second).Err()
 }
 
-func (r *Repository) GetCache(key string, value interface{}) (err error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+func (r *Repository) GetCache(ctx context.Context, key string, value interface{}) (err error) {
+	ctx, span := startSpan(ctx, "GetCache")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
 	v, err := r.client.Get(ctx, key).Result()
@@ -38,3 +71,240 @@ func (r *Repository) GetCache(key string, value interface{}) (err error) {
 
 	return json.Unmarshal([]byte(v), value)
 }
+
+// GetManyCache unmarshal each found key into the value at the same index, found reports which keys exist
+func (r *Repository) GetManyCache(ctx context.Context, keys []string, values []interface{}) (found []bool, err error) {
+	ctx, span := startSpan(ctx, "GetManyCache")
+	defer func() { endSpan(span, err) }()
+	span.SetAttributes(attribute.Int("db.redis.keys", len(keys)))
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	vs, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return
+	}
+
+	found = make([]bool, len(keys))
+	for i, v := range vs {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+
+		if err = json.Unmarshal([]byte(str), values[i]); err != nil {
+			return nil, err
+		}
+		found[i] = true
+	}
+
+	return
+}
+
+// GetCacheWithTTL is GetCache that also returns the remaining ttl of the key, zero when the key never expires
+func (r *Repository) GetCacheWithTTL(ctx context.Context, key string, value interface{}) (ttl time.Duration, err error) {
+	ctx, span := startSpan(ctx, "GetCacheWithTTL")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	pipe := r.client.Pipeline()
+	get := pipe.Get(ctx, key)
+	pttl := pipe.PTTL(ctx, key)
+	if _, err = pipe.Exec(ctx); err != nil {
+		return
+	}
+
+	if err = json.Unmarshal([]byte(get.Val()), value); err != nil {
+		return
+	}
+
+	return remainingTTL(pttl.Val()), nil
+}
+
+// GetManyCacheWithTTL is GetManyCache that also returns the remaining ttl of each key
+func (r *Repository) GetManyCacheWithTTL(ctx context.Context, keys []string, values []interface{}) (found []bool, ttls []time.Duration, err error) {
+	ctx, span := startSpan(ctx, "GetManyCacheWithTTL")
+	defer func() { endSpan(span, err) }()
+	span.SetAttributes(attribute.Int("db.redis.keys", len(keys)))
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	pipe := r.client.Pipeline()
+	mget := pipe.MGet(ctx, keys...)
+	pttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		pttls[i] = pipe.PTTL(ctx, key)
+	}
+
+	if _, err = pipe.Exec(ctx); err != nil {
+		return
+	}
+
+	found = make([]bool, len(keys))
+	ttls = make([]time.Duration, len(keys))
+	for i, v := range mget.Val() {
+		str, ok := v.(string)
+		if !ok {
+			continue
+		}
+
+		if err = json.Unmarshal([]byte(str), values[i]); err != nil {
+			return nil, nil, err
+		}
+		found[i] = true
+		ttls[i] = remainingTTL(pttls[i].Val())
+	}
+
+	return
+}
+
+// Increment counts atomically, the ttl is set by the same script that creates the counter
+func (r *Repository) Increment(ctx context.Context, key string, ttl int) (n int64, err error) {
+	ctx, span := startSpan(ctx, "Increment")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return incrementScript.Run(ctx, r.client, []string{key}, ttl).Int64()
+}
+
+// AcquireLock stores a random token in the lock and returns it, an empty token means the lock is held by another caller
+func (r *Repository) AcquireLock(ctx context.Context, key string, ttl int) (token string, err error) {
+	ctx, span := startSpan(ctx, "AcquireLock")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	token = uuid.NewString()
+	acquired, err := r.client.SetNX(ctx, key, token, time.Duration(ttl)*time.Second).Result()
+	if err != nil || !acquired {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ReleaseLock deletes the lock only when it still holds the token, a lock expired and taken by another caller is kept
+func (r *Repository) ReleaseLock(ctx context.Context, key string, token string) (err error) {
+	ctx, span := startSpan(ctx, "ReleaseLock")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return releaseScript.Run(ctx, r.client, []string{key}, token).Err()
+}
+
+func (r *Repository) DeleteCache(ctx context.Context, keys ...string) (deleted int64, err error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	ctx, span := startSpan(ctx, "DeleteCache")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	return r.client.Del(ctx, keys...).Result()
+}
+
+// DeleteCacheByPattern scans the keys matching the glob pattern and deletes them in batches
+func (r *Repository) DeleteCacheByPattern(ctx context.Context, pattern string) (deleted int64, err error) {
+	ctx, span := startSpan(ctx, "DeleteCacheByPattern")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+
+	var cursor uint64
+	for {
+		keys, next, err := r.client.Scan(ctx, cursor, pattern, scanBatchSize).Result()
+		if err != nil {
+			return deleted, err
+		}
+
+		if len(keys) > 0 {
+			n, err := r.client.Unlink(ctx, keys...).Result()
+			if err != nil {
+				return deleted, err
+			}
+			deleted += n
+		}
+
+		if next == 0 {
+			return deleted, nil
+		}
+		cursor = next
+	}
+}
+
+// Publish sends the value as json on the channel
+func (r *Repository) Publish(ctx context.Context, channel string, value interface{}) (err error) {
+	ctx, span := startSpan(ctx, "Publish")
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	v, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+
+	return r.client.Publish(ctx, channel, v).Err()
+}
+
+// Subscribe calls the handler with each message of the channel until the context is done
+func (r *Repository) Subscribe(ctx context.Context, channel string, handle func([]byte)) error {
+	pubsub := r.client.Subscribe(ctx, channel)
+	defer pubsub.Close()
+
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-messages:
+			if !ok {
+				return redis.ErrClosed
+			}
+			handle([]byte(msg.Payload))
+		}
+	}
+}
+
+// remainingTTL turns the negative ttl of a key without expiry into zero
+func remainingTTL(ttl time.Duration) time.Duration {
+	if ttl < 0 {
+		return 0
+	}
+
+	return ttl
+}
+
+func startSpan(ctx context.Context, operation string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "redis."+operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		semconv.DBSystemRedis,
+		attribute.String("db.operation", operation),
+	))
+}
+
+// endSpan records the error on the span, a missing key is a cache miss and is not marked as an error
+func endSpan(span trace.Span, err error) {
+	if err != nil && err != redis.Nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
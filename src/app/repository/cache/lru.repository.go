@@ -0,0 +1,352 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/isd-sgcu/rnkm65-file/src/app/utils"
+	"github.com/rs/zerolog/log"
+	"path"
+	"sync"
+	"time"
+)
+
+type remoteRepository interface {
+	SaveCache(context.Context, string, interface{}, int) error
+	GetCacheWithTTL(context.Context, string, interface{}) (time.Duration, error)
+	GetManyCacheWithTTL(context.Context, []string, []interface{}) ([]bool, []time.Duration, error)
+	Increment(context.Context, string, int) (int64, error)
+	AcquireLock(context.Context, string, int) (string, error)
+	ReleaseLock(context.Context, string, string) error
+	DeleteCache(context.Context, ...string) (int64, error)
+	DeleteCacheByPattern(context.Context, string) (int64, error)
+	Publish(context.Context, string, interface{}) error
+	Subscribe(context.Context, string, func([]byte)) error
+}
+
+const resubscribeDelay = time.Second
+
+// invalidation is published whenever a replica changes an entry so the others drop their local copy
+type invalidation struct {
+	Origin  string   `json:"origin"`
+	Keys    []string `json:"keys,omitempty"`
+	Pattern string   `json:"pattern,omitempty"`
+}
+
+type entry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// LRURepository keeps the recently used entries in memory in front of the redis repository,
+// the redis read errors are returned as is so the caller counts them and falls through to the database.
+// The changes are published on the invalidation channel, a local copy is never kept longer than the local ttl
+// nor the remaining ttl of the redis key, so the local ttl is the longest a replica serves a stale entry
+// when it misses an invalidation
+type LRURepository struct {
+	remote  remoteRepository
+	size    int
+	ttl     time.Duration
+	now     func() time.Time
+	channel string
+	origin  string
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mu    sync.Mutex
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+func NewLRURepository(remote remoteRepository, size int, ttl int, channel string) *LRURepository {
+	return &LRURepository{
+		remote:  remote,
+		size:    size,
+		ttl:     time.Duration(ttl) * time.Second,
+		now:     time.Now,
+		channel: channel,
+		origin:  uuid.NewString(),
+		ll:      list.New(),
+		items:   map[string]*list.Element{},
+	}
+}
+
+// Start listens to the invalidations of the other replicas until Stop is called, it subscribes again when
+// the connection is lost
+func (r *LRURepository) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+	r.done = make(chan struct{})
+
+	go func() {
+		defer close(r.done)
+
+		for {
+			err := r.subscribe(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+
+			log.Warn().
+				Err(err).
+				Str("module", "lru cache").
+				Str("channel", r.channel).
+				Msg("Lost the invalidation channel, the local entries expire after the local ttl")
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(resubscribeDelay):
+			}
+		}
+	}()
+}
+
+// subscribe evicts the invalidated entries until the subscription ends, a panic ends it and it is subscribed again
+func (r *LRURepository) subscribe(ctx context.Context) error {
+	defer utils.Recover("cache invalidation")
+
+	return r.remote.Subscribe(ctx, r.channel, r.invalidate)
+}
+
+func (r *LRURepository) Stop() {
+	if r.cancel == nil {
+		return
+	}
+
+	r.cancel()
+	<-r.done
+}
+
+func (r *LRURepository) SaveCache(ctx context.Context, key string, value interface{}, ttl int) error {
+	v, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	r.set(key, v, time.Duration(ttl)*time.Second)
+
+	if err := r.remote.SaveCache(ctx, key, value, ttl); err != nil {
+		log.Warn().
+			Err(err).
+			Str("module", "lru cache").
+			Str("key", key).
+			Msg("Cannot save to redis, keeping the local copy only")
+	}
+
+	r.publish(ctx, &invalidation{Keys: []string{key}})
+
+	return nil
+}
+
+func (r *LRURepository) GetCache(ctx context.Context, key string, value interface{}) error {
+	if v, ok := r.get(key); ok {
+		return json.Unmarshal(v, value)
+	}
+
+	ttl, err := r.remote.GetCacheWithTTL(ctx, key, value)
+	if err != nil {
+		return err
+	}
+
+	r.store(key, value, ttl)
+
+	return nil
+}
+
+func (r *LRURepository) GetManyCache(ctx context.Context, keys []string, values []interface{}) ([]bool, error) {
+	found := make([]bool, len(keys))
+
+	var missKeys []string
+	var missIdx []int
+	for i, key := range keys {
+		if v, ok := r.get(key); ok {
+			if err := json.Unmarshal(v, values[i]); err != nil {
+				return nil, err
+			}
+			found[i] = true
+			continue
+		}
+
+		missKeys = append(missKeys, key)
+		missIdx = append(missIdx, i)
+	}
+
+	if len(missKeys) == 0 {
+		return found, nil
+	}
+
+	missValues := make([]interface{}, len(missIdx))
+	for i, idx := range missIdx {
+		missValues[i] = values[idx]
+	}
+
+	remoteFound, ttls, err := r.remote.GetManyCacheWithTTL(ctx, missKeys, missValues)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, ok := range remoteFound {
+		if ok {
+			found[missIdx[i]] = true
+			r.store(missKeys[i], missValues[i], ttls[i])
+		}
+	}
+
+	return found, nil
+}
+
+// Increment always goes to redis, a counter cannot be served from a local copy
+func (r *LRURepository) Increment(ctx context.Context, key string, ttl int) (int64, error) {
+	return r.remote.Increment(ctx, key, ttl)
+}
+
+// AcquireLock always goes to redis, the caller decides whether to go on without the lock when redis is down
+func (r *LRURepository) AcquireLock(ctx context.Context, key string, ttl int) (string, error) {
+	return r.remote.AcquireLock(ctx, key, ttl)
+}
+
+func (r *LRURepository) ReleaseLock(ctx context.Context, key string, token string) error {
+	if err := r.remote.ReleaseLock(ctx, key, token); err != nil {
+		log.Warn().
+			Err(err).
+			Str("module", "lru cache").
+			Str("key", key).
+			Msg("Cannot release lock from redis")
+	}
+
+	return nil
+}
+
+// DeleteCache drops the local entries and publishes the keys so the other replicas drop theirs
+func (r *LRURepository) DeleteCache(ctx context.Context, keys ...string) (int64, error) {
+	r.evict(keys, "")
+
+	deleted, err := r.remote.DeleteCache(ctx, keys...)
+	r.publish(ctx, &invalidation{Keys: keys})
+
+	return deleted, err
+}
+
+func (r *LRURepository) DeleteCacheByPattern(ctx context.Context, pattern string) (int64, error) {
+	r.evict(nil, pattern)
+
+	deleted, err := r.remote.DeleteCacheByPattern(ctx, pattern)
+	r.publish(ctx, &invalidation{Pattern: pattern})
+
+	return deleted, err
+}
+
+func (r *LRURepository) publish(ctx context.Context, msg *invalidation) {
+	msg.Origin = r.origin
+	if err := r.remote.Publish(ctx, r.channel, msg); err != nil {
+		log.Warn().
+			Err(err).
+			Str("module", "lru cache").
+			Str("channel", r.channel).
+			Msg("Cannot publish the invalidation, the other replicas expire the entries after the local ttl")
+	}
+}
+
+func (r *LRURepository) invalidate(payload []byte) {
+	msg := &invalidation{}
+	if err := json.Unmarshal(payload, msg); err != nil {
+		log.Warn().
+			Err(err).
+			Str("module", "lru cache").
+			Str("channel", r.channel).
+			Msg("Cannot parse the invalidation")
+		return
+	}
+
+	if msg.Origin == r.origin {
+		return
+	}
+
+	r.evict(msg.Keys, msg.Pattern)
+}
+
+func (r *LRURepository) evict(keys []string, pattern string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, key := range keys {
+		if el, ok := r.items[key]; ok {
+			r.remove(el)
+		}
+	}
+
+	if pattern == "" {
+		return
+	}
+
+	for key, el := range r.items {
+		if ok, _ := path.Match(pattern, key); ok {
+			r.remove(el)
+		}
+	}
+}
+
+// store keeps the value read from redis no longer than the remaining ttl of the redis key
+func (r *LRURepository) store(key string, value interface{}, ttl time.Duration) {
+	v, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+
+	r.set(key, v, ttl)
+}
+
+func (r *LRURepository) set(key string, value []byte, ttl time.Duration) {
+	if ttl <= 0 || ttl > r.ttl {
+		ttl = r.ttl
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if el, ok := r.items[key]; ok {
+		r.ll.MoveToFront(el)
+		e := el.Value.(*entry)
+		e.value = value
+		e.expiresAt = r.now().Add(ttl)
+		return
+	}
+
+	r.items[key] = r.ll.PushFront(&entry{
+		key:       key,
+		value:     value,
+		expiresAt: r.now().Add(ttl),
+	})
+
+	for r.ll.Len() > r.size {
+		r.remove(r.ll.Back())
+	}
+}
+
+func (r *LRURepository) get(key string) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	el, ok := r.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	e := el.Value.(*entry)
+	if !r.now().Before(e.expiresAt) {
+		r.remove(el)
+		return nil, false
+	}
+
+	r.ll.MoveToFront(el)
+
+	return e.value, true
+}
+
+func (r *LRURepository) remove(el *list.Element) {
+	r.ll.Remove(el)
+	delete(r.items, el.Value.(*entry).key)
+}
@@ -0,0 +1,249 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-redis/redis/v8"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type remoteMock struct {
+	v         map[string][]byte
+	ttl       map[string]time.Duration
+	published []*invalidation
+	err       error
+}
+
+func (r *remoteMock) SaveCache(_ context.Context, key string, value interface{}, _ int) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	r.v[key], _ = json.Marshal(value)
+
+	return nil
+}
+
+func (r *remoteMock) GetCacheWithTTL(_ context.Context, key string, value interface{}) (time.Duration, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	v, ok := r.v[key]
+	if !ok {
+		return 0, redis.Nil
+	}
+
+	return r.ttl[key], json.Unmarshal(v, value)
+}
+
+func (r *remoteMock) GetManyCacheWithTTL(ctx context.Context, keys []string, values []interface{}) ([]bool, []time.Duration, error) {
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+
+	found := make([]bool, len(keys))
+	ttls := make([]time.Duration, len(keys))
+	for i, key := range keys {
+		ttl, err := r.GetCacheWithTTL(ctx, key, values[i])
+		found[i], ttls[i] = err == nil, ttl
+	}
+
+	return found, ttls, nil
+}
+
+func (r *remoteMock) Increment(context.Context, string, int) (int64, error) {
+	return 0, r.err
+}
+
+func (r *remoteMock) AcquireLock(context.Context, string, int) (string, error) {
+	return "", r.err
+}
+
+func (r *remoteMock) ReleaseLock(context.Context, string, string) error {
+	return r.err
+}
+
+func (r *remoteMock) DeleteCache(_ context.Context, keys ...string) (int64, error) {
+	for _, key := range keys {
+		delete(r.v, key)
+	}
+
+	return int64(len(keys)), r.err
+}
+
+func (r *remoteMock) DeleteCacheByPattern(context.Context, string) (int64, error) {
+	return 0, r.err
+}
+
+func (r *remoteMock) Publish(_ context.Context, _ string, value interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	r.published = append(r.published, value.(*invalidation))
+
+	return nil
+}
+
+func (r *remoteMock) Subscribe(ctx context.Context, _ string, _ func([]byte)) error {
+	<-ctx.Done()
+
+	return nil
+}
+
+type LRURepositoryTest struct {
+	suite.Suite
+	remote *remoteMock
+	now    time.Time
+	repo   *LRURepository
+}
+
+func TestLRURepository(t *testing.T) {
+	suite.Run(t, new(LRURepositoryTest))
+}
+
+func (t *LRURepositoryTest) SetupTest() {
+	t.remote = &remoteMock{v: map[string][]byte{}, ttl: map[string]time.Duration{}}
+	t.now = time.Now()
+	t.repo = NewLRURepository(t.remote, 2, 10, "rnkm65-file:invalidation")
+	t.repo.now = func() time.Time { return t.now }
+}
+
+func (t *LRURepositoryTest) TestGetFromLocalWhenRedisDown() {
+	assert.Nil(t.T(), t.repo.SaveCache(context.Background(), "a", &dto.CacheFile{Url: "url"}, 900))
+
+	t.remote.err = errors.New("Cannot connect to redis server")
+
+	actual := &dto.CacheFile{}
+	assert.Nil(t.T(), t.repo.GetCache(context.Background(), "a", actual))
+	assert.Equal(t.T(), "url", actual.Url)
+}
+
+func (t *LRURepositoryTest) TestSaveWhenRedisDown() {
+	t.remote.err = errors.New("Cannot connect to redis server")
+
+	assert.Nil(t.T(), t.repo.SaveCache(context.Background(), "a", &dto.CacheFile{Url: "url"}, 900))
+
+	token, err := t.repo.AcquireLock(context.Background(), "lock", 1)
+	assert.Equal(t.T(), t.remote.err, err)
+	assert.Empty(t.T(), token)
+}
+
+func (t *LRURepositoryTest) TestRedisErrorIsReturned() {
+	t.remote.err = errors.New("Cannot connect to redis server")
+
+	err := t.repo.GetCache(context.Background(), "a", &dto.CacheFile{})
+	assert.Equal(t.T(), t.remote.err, err)
+
+	found, err := t.repo.GetManyCache(context.Background(), []string{"a"}, []interface{}{&dto.CacheFile{}})
+	assert.Equal(t.T(), t.remote.err, err)
+	assert.Nil(t.T(), found)
+}
+
+func (t *LRURepositoryTest) TestLocalTTLIsBounded() {
+	assert.Nil(t.T(), t.repo.SaveCache(context.Background(), "a", &dto.CacheFile{Url: "url"}, 900))
+	delete(t.remote.v, "a")
+
+	t.now = t.now.Add(11 * time.Second)
+
+	err := t.repo.GetCache(context.Background(), "a", &dto.CacheFile{})
+	assert.Equal(t.T(), redis.Nil, err)
+}
+
+func (t *LRURepositoryTest) TestLocalTTLIsCappedByRedis() {
+	t.remote.v["a"], _ = json.Marshal(&dto.CacheFile{Url: "url"})
+	t.remote.ttl["a"] = 2 * time.Second
+
+	assert.Nil(t.T(), t.repo.GetCache(context.Background(), "a", &dto.CacheFile{}))
+
+	t.remote.err = errors.New("Cannot connect to redis server")
+	t.now = t.now.Add(3 * time.Second)
+
+	err := t.repo.GetCache(context.Background(), "a", &dto.CacheFile{})
+	assert.Equal(t.T(), t.remote.err, err)
+}
+
+func (t *LRURepositoryTest) TestEvictLeastRecentlyUsed() {
+	assert.Nil(t.T(), t.repo.SaveCache(context.Background(), "a", &dto.CacheFile{Url: "a"}, 900))
+	assert.Nil(t.T(), t.repo.SaveCache(context.Background(), "b", &dto.CacheFile{Url: "b"}, 900))
+	assert.Nil(t.T(), t.repo.GetCache(context.Background(), "a", &dto.CacheFile{}))
+	assert.Nil(t.T(), t.repo.SaveCache(context.Background(), "c", &dto.CacheFile{Url: "c"}, 900))
+
+	t.remote.err = errors.New("Cannot connect to redis server")
+
+	assert.Nil(t.T(), t.repo.GetCache(context.Background(), "a", &dto.CacheFile{}))
+	assert.Nil(t.T(), t.repo.GetCache(context.Background(), "c", &dto.CacheFile{}))
+	assert.Equal(t.T(), t.remote.err, t.repo.GetCache(context.Background(), "b", &dto.CacheFile{}))
+}
+
+func (t *LRURepositoryTest) TestGetManyFillFromRedis() {
+	assert.Nil(t.T(), t.repo.SaveCache(context.Background(), "a", &dto.CacheFile{Url: "a"}, 900))
+	t.remote.v["b"], _ = json.Marshal(&dto.CacheFile{Url: "b"})
+
+	a, b, c := &dto.CacheFile{}, &dto.CacheFile{}, &dto.CacheFile{}
+	found, err := t.repo.GetManyCache(context.Background(), []string{"a", "b", "c"}, []interface{}{a, b, c})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), []bool{true, true, false}, found)
+	assert.Equal(t.T(), "a", a.Url)
+	assert.Equal(t.T(), "b", b.Url)
+
+	t.remote.err = errors.New("Cannot connect to redis server")
+	assert.Nil(t.T(), t.repo.GetCache(context.Background(), "b", &dto.CacheFile{}))
+}
+
+func (t *LRURepositoryTest) TestDeleteLocalEntries() {
+	assert.Nil(t.T(), t.repo.SaveCache(context.Background(), "rnkm65-file:v1:file:owner:a", &dto.CacheFile{Url: "a"}, 900))
+	assert.Nil(t.T(), t.repo.SaveCache(context.Background(), "rnkm65-file:v1:file:owner:b", &dto.CacheFile{Url: "b"}, 900))
+
+	_, err := t.repo.DeleteCache(context.Background(), "rnkm65-file:v1:file:owner:a")
+	assert.Nil(t.T(), err)
+	_, err = t.repo.DeleteCacheByPattern(context.Background(), "rnkm65-file:*:file:owner:b")
+	assert.Nil(t.T(), err)
+
+	t.remote.err = errors.New("Cannot connect to redis server")
+
+	assert.Equal(t.T(), t.remote.err, t.repo.GetCache(context.Background(), "rnkm65-file:v1:file:owner:a", &dto.CacheFile{}))
+	assert.Equal(t.T(), t.remote.err, t.repo.GetCache(context.Background(), "rnkm65-file:v1:file:owner:b", &dto.CacheFile{}))
+}
+
+func (t *LRURepositoryTest) TestPublishInvalidations() {
+	assert.Nil(t.T(), t.repo.SaveCache(context.Background(), "a", &dto.CacheFile{Url: "a"}, 900))
+	_, err := t.repo.DeleteCache(context.Background(), "a")
+	assert.Nil(t.T(), err)
+	_, err = t.repo.DeleteCacheByPattern(context.Background(), "rnkm65-file:*:file:owner:b")
+	assert.Nil(t.T(), err)
+
+	assert.Equal(t.T(), []*invalidation{
+		{Origin: t.repo.origin, Keys: []string{"a"}},
+		{Origin: t.repo.origin, Keys: []string{"a"}},
+		{Origin: t.repo.origin, Pattern: "rnkm65-file:*:file:owner:b"},
+	}, t.remote.published)
+}
+
+func (t *LRURepositoryTest) TestInvalidateFromAnotherReplica() {
+	assert.Nil(t.T(), t.repo.SaveCache(context.Background(), "rnkm65-file:v1:file:owner:a", &dto.CacheFile{Url: "a"}, 900))
+	assert.Nil(t.T(), t.repo.SaveCache(context.Background(), "rnkm65-file:v1:file:owner:b", &dto.CacheFile{Url: "b"}, 900))
+
+	own, _ := json.Marshal(&invalidation{Origin: t.repo.origin, Keys: []string{"rnkm65-file:v1:file:owner:a"}})
+	t.repo.invalidate(own)
+
+	other, _ := json.Marshal(&invalidation{Origin: "another replica", Pattern: "rnkm65-file:*:file:owner:b"})
+	t.repo.invalidate(other)
+
+	t.remote.err = errors.New("Cannot connect to redis server")
+
+	assert.Nil(t.T(), t.repo.GetCache(context.Background(), "rnkm65-file:v1:file:owner:a", &dto.CacheFile{}))
+	assert.Equal(t.T(), t.remote.err, t.repo.GetCache(context.Background(), "rnkm65-file:v1:file:owner:b", &dto.CacheFile{}))
+}
+
+func (t *LRURepositoryTest) TestStartStop() {
+	t.repo.Start()
+	t.repo.Stop()
+}
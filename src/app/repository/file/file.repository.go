@@ -1,29 +1,175 @@
 package file
 
 import (
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"github.com/isd-sgcu/rnkm65-file/src/app/apperror"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/event"
 	"github.com/isd-sgcu/rnkm65-file/src/app/model/file"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/outbox"
+	"github.com/isd-sgcu/rnkm65-file/src/constant/event"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Repository struct {
-	db *gorm.DB
+	db     *gorm.DB
+	events bool
 }
 
-func NewRepository(db *gorm.DB) *Repository {
-	return &Repository{db: db}
+// NewRepository writes the file events into the outbox with every change when events is on
+func NewRepository(db *gorm.DB, events bool) *Repository {
+	return &Repository{
+		db:     db,
+		events: events,
+	}
+}
+
+func (r *Repository) FindByID(ctx context.Context, id string, result *file.File) error {
+	return r.db.WithContext(ctx).First(&result, "id = ?", id).Error
+}
+
+func (r *Repository) FindByOwnerID(ctx context.Context, id string, result *file.File) error {
+	return r.db.WithContext(ctx).First(&result, "owner_id = ?", id).Error
+}
+
+func (r *Repository) FindByOwnerIDs(ctx context.Context, ids []string, result *[]file.File) error {
+	return r.db.WithContext(ctx).Find(&result, "owner_id IN ?", ids).Error
 }
 
-func (r *Repository) FindByOwnerID(id string, result *file.File) error {
-	return r.db.First(&result, "owner_id = ?", id).Error
+func (r *Repository) FindByTag(ctx context.Context, tag int, result *[]file.File) error {
+	return r.db.WithContext(ctx).Select("owner_id").Find(&result, "tag = ?", tag).Error
 }
 
-func (r *Repository) CreateOrUpdate(result *file.File) error {
-	if r.db.Where("owner_id = ?", result.OwnerID).Updates(&result).RowsAffected == 0 {
-		return r.db.Create(&result).Error
+func (r *Repository) FindRecent(ctx context.Context, limit int, result *[]file.File) error {
+	return r.db.WithContext(ctx).Order("updated_at desc").Limit(limit).Find(&result).Error
+}
+
+// CreateOrUpdate saves the file of the owner in one upsert and bumps its version, with a non zero version the row
+// is only replaced while it is still at that version and apperror.ErrVersionMismatch is returned otherwise.
+// The replaced row is returned so its object can be deleted once committed, it is nil when the file is new
+func (r *Repository) CreateOrUpdate(ctx context.Context, result *file.File, version int64) (*file.File, error) {
+	var previous *file.File
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// the row is locked so a concurrent upload cannot replace it before this one reads its object
+		existing := &file.File{}
+		res := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Where("owner_id = ?", result.OwnerID).Limit(1).Find(existing)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected > 0 {
+			previous = existing
+		}
+
+		var id uuid.UUID
+		if version > 0 {
+			res := tx.Model(&file.File{}).
+				Where("owner_id = ? AND version = ?", result.OwnerID, version).
+				Updates(map[string]interface{}{
+					"filename": result.Filename,
+					"name":     result.Name,
+					"tag":      result.Tag,
+					"type":     result.Type,
+					"public":   result.Public,
+					"version":  gorm.Expr("version + 1"),
+				})
+			if res.Error != nil {
+				return res.Error
+			}
+
+			if res.RowsAffected == 0 {
+				return apperror.ErrVersionMismatch
+			}
+		} else {
+			err := tx.Clauses(clause.OnConflict{
+				Columns: []clause.Column{{Name: "owner_id"}},
+				DoUpdates: append(
+					clause.AssignmentColumns([]string{"filename", "name", "tag", "type", "public", "updated_at", "deleted_at"}),
+					clause.Assignment{Column: clause.Column{Name: "version"}, Value: gorm.Expr("version + 1")},
+				),
+			}).Create(&result).Error
+			if err != nil {
+				return err
+			}
+
+			// the id is only kept when the row was inserted, the existing row keeps its own id
+			id = result.ID
+		}
+
+		f := &file.File{}
+		if err := tx.First(f, "owner_id = ?", result.OwnerID).Error; err != nil {
+			return err
+		}
+		*result = *f
+
+		if result.ID == id {
+			return r.append(tx, event.FILE_UPLOADED, result)
+		}
+
+		return r.append(tx, event.FILE_REPLACED, result)
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil
+
+	return previous, nil
+}
+
+// Delete removes the row for good, a soft deleted row would keep the owner id taken for the next upload,
+// with a non zero version the row is only removed while it is still at that version
+func (r *Repository) Delete(ctx context.Context, id string, version int64) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		f := &file.File{}
+		if err := tx.First(f, "id = ?", id).Error; err != nil {
+			return err
+		}
+
+		query := tx.Unscoped()
+		if version > 0 {
+			query = query.Where("version = ?", version)
+		}
+
+		res := query.Delete(f)
+		if res.Error != nil {
+			return res.Error
+		}
+
+		if res.RowsAffected == 0 && version > 0 {
+			return apperror.ErrVersionMismatch
+		}
+
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return r.append(tx, event.FILE_DELETED, f)
+	})
 }
 
-func (r *Repository) Delete(id string) error {
-	return r.db.First(id).Delete(&file.File{}).Error
+func (r *Repository) append(tx *gorm.DB, eventType event.Type, f *file.File) error {
+	if !r.events {
+		return nil
+	}
+
+	payload, err := json.Marshal(&dto.File{
+		ID:       f.ID.String(),
+		OwnerID:  f.OwnerID,
+		Filename: f.Filename,
+		Tag:      f.Tag,
+		Type:     f.Type,
+		Public:   f.Public,
+		Version:  f.Version,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&outbox.Event{
+		Type:        string(eventType),
+		AggregateID: f.ID.String(),
+		Payload:     string(payload),
+	}).Error
 }
@@ -0,0 +1,39 @@
+package link
+
+import (
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/link"
+	"gorm.io/gorm"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) FindByID(id string, result *link.Link) error {
+	return r.db.First(&result, "id = ?", id).Error
+}
+
+func (r *Repository) FindByTokenHash(hash string, result *link.Link) error {
+	return r.db.First(&result, "token_hash = ?", hash).Error
+}
+
+func (r *Repository) Create(result *link.Link) error {
+	return r.db.Create(&result).Error
+}
+
+func (r *Repository) Delete(id string) error {
+	res := r.db.Delete(&link.Link{}, "id = ?", id)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
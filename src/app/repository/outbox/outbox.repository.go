@@ -0,0 +1,83 @@
+package outbox
+
+import (
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/outbox"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Relay claims the oldest unpublished events for the lease and commits the claim before publishing them, so no row
+// is locked while the bus is called. The publishing stops at the first failure and the claim of the events left is
+// released, the events of a relay that stopped before marking them are claimed again once the lease is over.
+// The relays of the other replicas claim the next events at the same time, so the events are only published in order
+// within a batch and the consumers order the events of a file by its version
+func (r *Repository) Relay(limit int, lease time.Duration, publish func(*outbox.Event) error) (int, error) {
+	var events []outbox.Event
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_at IS NULL AND (claimed_until IS NULL OR claimed_until < ?)", now).
+			Order("id").
+			Limit(limit).
+			Find(&events).Error
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		return tx.Model(&outbox.Event{}).Where("id IN ?", eventIDs(events)).Update("claimed_until", now.Add(lease)).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	var publishErr error
+	for i := range events {
+		if publishErr = publish(&events[i]); publishErr != nil {
+			break
+		}
+		published++
+	}
+
+	if published > 0 {
+		err = r.db.Model(&outbox.Event{}).Where("id IN ?", eventIDs(events[:published])).Update("published_at", time.Now()).Error
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if published < len(events) {
+		err = r.db.Model(&outbox.Event{}).Where("id IN ?", eventIDs(events[published:])).Update("claimed_until", nil).Error
+		if err != nil && publishErr == nil {
+			publishErr = err
+		}
+	}
+
+	return published, publishErr
+}
+
+func eventIDs(events []outbox.Event) []uint64 {
+	ids := make([]uint64, len(events))
+	for i, e := range events {
+		ids[i] = e.ID
+	}
+
+	return ids
+}
+
+// DeletePublished removes the events published before the time
+func (r *Repository) DeletePublished(before time.Time) (int64, error) {
+	res := r.db.Where("published_at < ?", before).Delete(&outbox.Event{})
+
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,71 @@
+package share
+
+import (
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/share"
+	constant "github.com/isd-sgcu/rnkm65-file/src/constant/share"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// CreateOrUpdate grants the file in one upsert so concurrent grants to the same grantee do not race,
+// the existing grant keeps its id and only gets the new expiry
+func (r *Repository) CreateOrUpdate(result *share.Share) error {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "owner_id"}, {Name: "grantee_type"}, {Name: "grantee_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"expires_at", "created_by", "updated_at", "deleted_at"}),
+	}).Create(&result).Error
+	if err != nil {
+		return err
+	}
+
+	// the id is only kept when the row was inserted, the existing row keeps its own id
+	sh := &share.Share{}
+	if err := r.db.First(sh, "owner_id = ? AND grantee_type = ? AND grantee_id = ?", result.OwnerID, result.GranteeType, result.GranteeID).Error; err != nil {
+		return err
+	}
+	*result = *sh
+
+	return nil
+}
+
+// Delete removes the grant for good so the same grantee can be granted again
+func (r *Repository) Delete(ownerID string, granteeType string, granteeID string) error {
+	res := r.db.Unscoped().Delete(&share.Share{}, "owner_id = ? AND grantee_type = ? AND grantee_id = ?", ownerID, granteeType, granteeID)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// FindSharedWith returns the unexpired grants to the user directly or to one of the groups
+func (r *Repository) FindSharedWith(userID string, groups []string, result *[]share.Share) error {
+	return r.active(userID, groups).Order("created_at desc").Find(&result).Error
+}
+
+// FindGranted returns the unexpired grants on the files of the owners to the user or one of the groups
+func (r *Repository) FindGranted(ownerIDs []string, userID string, groups []string, result *[]share.Share) error {
+	return r.active(userID, groups).Where("owner_id IN ?", ownerIDs).Find(&result).Error
+}
+
+func (r *Repository) active(userID string, groups []string) *gorm.DB {
+	grantee := r.db.Where("grantee_type = ? AND grantee_id = ?", constant.USER, userID)
+	if len(groups) > 0 {
+		grantee = grantee.Or("grantee_type = ? AND grantee_id IN ?", constant.GROUP, groups)
+	}
+
+	return r.db.Where(grantee).Where("expires_at IS NULL OR expires_at > ?", time.Now())
+}
@@ -0,0 +1,116 @@
+package webhook
+
+import (
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/webhook"
+	constant "github.com/isd-sgcu/rnkm65-file/src/constant/webhook"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type Repository struct {
+	db *gorm.DB
+}
+
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+func (r *Repository) FindSubscriptions(result *[]webhook.Subscription) error {
+	return r.db.Order("created_at").Find(&result).Error
+}
+
+func (r *Repository) FindSubscriptionByID(id string, result *webhook.Subscription) error {
+	return r.db.First(&result, "id = ?", id).Error
+}
+
+func (r *Repository) CreateSubscription(result *webhook.Subscription) error {
+	return r.db.Create(&result).Error
+}
+
+func (r *Repository) DeleteSubscription(id string) error {
+	res := r.db.Delete(&webhook.Subscription{}, "id = ?", id)
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// CreateDeliveries skips the deliveries of the events already queued for the subscription,
+// the relay may publish an event again after a failure
+func (r *Repository) CreateDeliveries(in []webhook.Delivery) error {
+	if len(in) == 0 {
+		return nil
+	}
+
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&in).Error
+}
+
+// ClaimDeliveries locks the due deliveries and moves their next attempt past the lease,
+// so the dispatcher of another replica does not post them while they are in flight
+func (r *Repository) ClaimDeliveries(limit int, lease time.Duration, result *[]webhook.Delivery) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", constant.PENDING, time.Now()).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&result).Error
+		if err != nil || len(*result) == 0 {
+			return err
+		}
+
+		ids := make([]string, len(*result))
+		for i, d := range *result {
+			ids[i] = d.ID.String()
+		}
+
+		return tx.Model(&webhook.Delivery{}).Where("id IN ?", ids).Update("next_attempt_at", time.Now().Add(lease)).Error
+	})
+}
+
+// UpdateDelivery saves the outcome of an attempt
+func (r *Repository) UpdateDelivery(in *webhook.Delivery) error {
+	return r.db.Model(in).
+		Select("status", "attempts", "next_attempt_at", "last_status_code", "last_error", "delivered_at", "updated_at").
+		Updates(in).Error
+}
+
+// FindDeliveries returns the newest deliveries first, the empty subscription id and status are not filtered on
+func (r *Repository) FindDeliveries(subscriptionId string, status string, limit int, result *[]webhook.Delivery) error {
+	query := r.db
+
+	if subscriptionId != "" {
+		query = query.Where("subscription_id = ?", subscriptionId)
+	}
+
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	return query.Order("created_at desc").Limit(limit).Find(&result).Error
+}
+
+// RetryDelivery queues a dead delivery again with its attempts reset
+func (r *Repository) RetryDelivery(id string, result *webhook.Delivery) error {
+	res := r.db.Model(&webhook.Delivery{}).
+		Where("id = ? AND status = ?", id, constant.DEAD).
+		Updates(map[string]interface{}{
+			"status":          constant.PENDING,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	if res.Error != nil {
+		return res.Error
+	}
+
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return r.db.First(&result, "id = ?", id).Error
+}
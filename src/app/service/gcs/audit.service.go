@@ -0,0 +1,121 @@
+package gcs
+
+import (
+	"context"
+	"github.com/isd-sgcu/rnkm65-file/src/app/apperror"
+	"github.com/isd-sgcu/rnkm65-file/src/app/auth"
+	auditDto "github.com/isd-sgcu/rnkm65-file/src/app/dto/audit"
+	auditModel "github.com/isd-sgcu/rnkm65-file/src/app/model/audit"
+	"github.com/isd-sgcu/rnkm65-file/src/constant/audit"
+	"github.com/isd-sgcu/rnkm65-file/src/proto"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+func (s *Service) QueryAuditLog(ctx context.Context, req *proto.QueryAuditLogRequest) (*proto.QueryAuditLogResponse, error) {
+	if err := s.authorize(ctx, "query audit log", auth.Admin, ""); err != nil {
+		return nil, err
+	}
+
+	if s.auditRepo == nil {
+		return nil, status.Error(codes.Unimplemented, "Audit log is disabled")
+	}
+
+	if req.Limit < 0 || req.Since < 0 || req.Until < 0 {
+		return nil, apperror.InvalidArgument("limit", "Limit and time range cannot be negative")
+	}
+
+	filter := &auditDto.Filter{
+		UserID: req.UserId,
+		FileID: req.FileId,
+		Limit:  int(req.Limit),
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = defaultAuditLimit
+	}
+
+	if filter.Limit > maxAuditLimit {
+		filter.Limit = maxAuditLimit
+	}
+
+	if req.Since != 0 {
+		filter.Since = time.Unix(req.Since, 0)
+	}
+
+	if req.Until != 0 {
+		filter.Until = time.Unix(req.Until, 0)
+	}
+
+	var entries []auditModel.Audit
+	err := s.auditRepo.Find(filter, &entries)
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "query audit log").
+			Str("user_id", req.UserId).
+			Str("file_id", req.FileId).
+			Msg("Error while trying to query data")
+		return nil, apperror.Unavailable(apperror.Database, err)
+	}
+
+	result := make([]*proto.AuditEntry, 0, len(entries))
+	for i := range entries {
+		result = append(result, rawToAuditEntry(&entries[i]))
+	}
+
+	return &proto.QueryAuditLogResponse{Entries: result}, nil
+}
+
+// record queues the access for the audit log, the repository inserts it in the background so the cached reads
+// do not wait for the database, the failures are logged with the whole entry so it can still be recovered from the logs
+func (s *Service) record(ctx context.Context, action audit.Action, userId string, fileId string, err error) {
+	if s.auditRepo == nil {
+		return
+	}
+
+	entry := &auditModel.Audit{
+		Action: string(action),
+		UserID: userId,
+		FileID: fileId,
+		Code:   status.Code(err).String(),
+	}
+
+	if caller, ok := auth.FromContext(ctx); ok {
+		entry.Service = caller.Service
+		entry.Subject = caller.Subject
+	}
+
+	if err := s.auditRepo.Create(entry); err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "audit").
+			Str("action", entry.Action).
+			Str("service", entry.Service).
+			Str("subject", entry.Subject).
+			Str("user_id", entry.UserID).
+			Str("file_id", entry.FileID).
+			Str("code", entry.Code).
+			Msg("Error while saving audit data")
+	}
+}
+
+func rawToAuditEntry(in *auditModel.Audit) *proto.AuditEntry {
+	return &proto.AuditEntry{
+		Id:        in.ID.String(),
+		Action:    in.Action,
+		Service:   in.Service,
+		Subject:   in.Subject,
+		UserId:    in.UserID,
+		FileId:    in.FileID,
+		Code:      in.Code,
+		CreatedAt: in.CreatedAt.Unix(),
+	}
+}
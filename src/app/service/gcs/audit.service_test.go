@@ -0,0 +1,150 @@
+package gcs
+
+import (
+	"context"
+	"github.com/bxcodec/faker/v3"
+	"github.com/google/uuid"
+	"github.com/isd-sgcu/rnkm65-file/src/app/auth"
+	auditDto "github.com/isd-sgcu/rnkm65-file/src/app/dto/audit"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/audit"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/share"
+	aMock "github.com/isd-sgcu/rnkm65-file/src/mocks/audit"
+	cMock "github.com/isd-sgcu/rnkm65-file/src/mocks/cache"
+	fMock "github.com/isd-sgcu/rnkm65-file/src/mocks/file"
+	mock "github.com/isd-sgcu/rnkm65-file/src/mocks/gcs"
+	lMock "github.com/isd-sgcu/rnkm65-file/src/mocks/link"
+	sMock "github.com/isd-sgcu/rnkm65-file/src/mocks/share"
+	"github.com/isd-sgcu/rnkm65-file/src/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+func (t *GCSServiceTest) TestUploadRecordsAudit() {
+	c := mock.ClientMock{}
+	c.On("Upload", t.file).Return(nil)
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return(t.url, nil)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("CreateOrUpdate", t.f.OwnerID, int64(0)).Return(t.f, nil, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("SaveCache", t.key, t.cacheFile.Url, t.ttl).Return(nil)
+
+	auditRepo := aMock.RepositoryMock{}
+	auditRepo.On("Create", "upload", t.f.OwnerID, t.f.ID.String(), "OK").Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, &auditRepo)
+
+	_, err := srv.Upload(context.Background(), &proto.UploadRequest{
+		Filename: t.filename,
+		Data:     t.file,
+		UserId:   t.f.OwnerID,
+		Tag:      1,
+		Type:     1,
+	})
+	srv.Wait()
+
+	assert.Nil(t.T(), err)
+	auditRepo.AssertExpectations(t.T())
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlDeniedRecordsAudit() {
+	t.authConf.Enabled = true
+	subject := faker.UUIDDigit()
+
+	shareRepo := sMock.RepositoryMock{}
+	shareRepo.On("FindGranted", []string{t.f.OwnerID}, subject, []string(nil)).Return([]share.Share{}, nil)
+
+	auditRepo := aMock.RepositoryMock{}
+	auditRepo.On("Create", "get_signed_url", t.f.OwnerID, "", "PermissionDenied").Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &shareRepo, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, &auditRepo)
+
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "gateway", Trusted: true, Subject: subject})
+
+	_, err := srv.GetSignedUrl(ctx, &proto.GetSignedUrlRequest{UserId: t.f.OwnerID})
+	srv.Wait()
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), codes.PermissionDenied, st.Code())
+	auditRepo.AssertExpectations(t.T())
+}
+
+func (t *GCSServiceTest) TestBatchGetSignedUrlsRecordsAuditPerUser() {
+	t.cacheFile.ID = t.f.ID.String()
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetManyCache", []string{t.key}).Return([]*dto.CacheFile{t.cacheFile}, nil)
+
+	auditRepo := aMock.RepositoryMock{}
+	auditRepo.On("Create", "batch_get_signed_urls", t.f.OwnerID, t.f.ID.String(), "OK").Return(nil).Once()
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, &auditRepo)
+
+	_, err := srv.BatchGetSignedUrls(context.Background(), &proto.BatchGetSignedUrlsRequest{
+		Items: []*proto.BatchGetSignedUrlsItem{{UserId: t.f.OwnerID}, {UserId: t.f.OwnerID, Tag: 1}},
+	})
+	srv.Wait()
+
+	assert.Nil(t.T(), err)
+	auditRepo.AssertExpectations(t.T())
+}
+
+func (t *GCSServiceTest) TestQueryAuditLogSuccess() {
+	since := time.Now().Add(-time.Hour).Truncate(time.Second)
+	entry := audit.Audit{
+		ID:        uuid.New(),
+		Action:    "get_signed_url",
+		Service:   "gateway",
+		Subject:   faker.UUIDDigit(),
+		UserID:    t.f.OwnerID,
+		FileID:    t.f.ID.String(),
+		Code:      "OK",
+		CreatedAt: time.Now().Truncate(time.Second),
+	}
+
+	want := &proto.QueryAuditLogResponse{Entries: []*proto.AuditEntry{{
+		Id:        entry.ID.String(),
+		Action:    entry.Action,
+		Service:   entry.Service,
+		Subject:   entry.Subject,
+		UserId:    entry.UserID,
+		FileId:    entry.FileID,
+		Code:      entry.Code,
+		CreatedAt: entry.CreatedAt.Unix(),
+	}}}
+
+	auditRepo := aMock.RepositoryMock{}
+	auditRepo.On("Find", &auditDto.Filter{UserID: t.f.OwnerID, Since: since, Limit: defaultAuditLimit}).Return([]audit.Audit{entry}, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, &auditRepo)
+
+	actual, err := srv.QueryAuditLog(context.Background(), &proto.QueryAuditLogRequest{UserId: t.f.OwnerID, Since: since.Unix()})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+}
+
+func (t *GCSServiceTest) TestQueryAuditLogNotAdmin() {
+	t.authConf.Enabled = true
+
+	auditRepo := aMock.RepositoryMock{}
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, &auditRepo)
+
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "checkin", Permissions: []auth.Permission{auth.Read}})
+
+	actual, err := srv.QueryAuditLog(ctx, &proto.QueryAuditLogRequest{UserId: t.f.OwnerID})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.PermissionDenied, st.Code())
+	auditRepo.AssertNotCalled(t.T(), "Find")
+}
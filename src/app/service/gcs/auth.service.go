@@ -0,0 +1,124 @@
+package gcs
+
+import (
+	"context"
+	"github.com/isd-sgcu/rnkm65-file/src/app/apperror"
+	"github.com/isd-sgcu/rnkm65-file/src/app/auth"
+	shareModel "github.com/isd-sgcu/rnkm65-file/src/app/model/share"
+	"github.com/rs/zerolog/log"
+)
+
+// userId returns the user the call is made for, the verified subject is used when the request field is empty.
+// A request field naming another user than the verified subject is never taken on trust, it is left to authorize
+// which only allows the callers granted the permission on every file and denies the others
+func (s *Service) userId(ctx context.Context, requested string) string {
+	if requested != "" || !s.authConf.Enabled {
+		return requested
+	}
+
+	if caller, ok := auth.FromContext(ctx); ok {
+		return caller.Subject
+	}
+
+	return requested
+}
+
+// authorize allows the owner, the admins, the services granted the permission on every file
+// and the trusted services calling without an end user
+func (s *Service) authorize(ctx context.Context, module string, permission auth.Permission, ownerId string) error {
+	if !s.authConf.Enabled {
+		return nil
+	}
+
+	caller, ok := auth.FromContext(ctx)
+	if ok && s.allowed(caller, permission, ownerId) {
+		return nil
+	}
+
+	return s.deny(ctx, module, permission, ownerId, caller)
+}
+
+// authorizeRead also allows the users and the groups the owner shared the file with
+func (s *Service) authorizeRead(ctx context.Context, module string, ownerId string) error {
+	denied, err := s.authorizeReadMany(ctx, module, []string{ownerId})
+	if err != nil {
+		return err
+	}
+
+	if denied[ownerId] {
+		return apperror.PermissionDenied(string(auth.Read))
+	}
+
+	return nil
+}
+
+// authorizeReadMany returns the owners the caller cannot read, the grants are looked up in a single query
+func (s *Service) authorizeReadMany(ctx context.Context, module string, ownerIds []string) (map[string]bool, error) {
+	denied := map[string]bool{}
+	if !s.authConf.Enabled {
+		return denied, nil
+	}
+
+	caller, ok := auth.FromContext(ctx)
+
+	var pending []string
+	for _, ownerId := range ownerIds {
+		if ok && s.allowed(caller, auth.Read, ownerId) {
+			continue
+		}
+
+		pending = append(pending, ownerId)
+	}
+
+	granted := map[string]bool{}
+	if ok && len(pending) > 0 && (caller.Subject != "" || len(caller.Groups) > 0) {
+		var shares []shareModel.Share
+		err := s.shareRepo.FindGranted(pending, caller.Subject, caller.Groups, &shares)
+		if err != nil {
+			log.Ctx(ctx).Error().
+				Err(err).
+				Str("module", module).
+				Str("user_id", caller.Subject).
+				Msg("Error while trying to query data")
+			return nil, apperror.Unavailable(apperror.Database, err)
+		}
+
+		for _, sh := range shares {
+			granted[sh.OwnerID] = true
+		}
+	}
+
+	for _, ownerId := range pending {
+		if !granted[ownerId] {
+			denied[ownerId] = true
+			_ = s.deny(ctx, module, auth.Read, ownerId, caller)
+		}
+	}
+
+	return denied, nil
+}
+
+func (s *Service) allowed(caller *auth.Caller, permission auth.Permission, ownerId string) bool {
+	if caller.IsOwner(ownerId) || caller.Can(permission) {
+		return true
+	}
+
+	return ownerId != "" && caller.Trusted && caller.Subject == ""
+}
+
+func (s *Service) deny(ctx context.Context, module string, permission auth.Permission, ownerId string, caller *auth.Caller) error {
+	if caller == nil {
+		caller = &auth.Caller{}
+	}
+
+	log.Ctx(ctx).Warn().
+		Str("module", "audit").
+		Str("action", module).
+		Str("permission", string(permission)).
+		Str("owner_id", ownerId).
+		Str("caller_service", caller.Service).
+		Str("caller_subject", caller.Subject).
+		Msg("Permission denied")
+
+	return apperror.PermissionDenied(string(permission))
+}
@@ -0,0 +1,239 @@
+package gcs
+
+import (
+	"context"
+	"github.com/bxcodec/faker/v3"
+	"github.com/isd-sgcu/rnkm65-file/src/app/auth"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/share"
+	cMock "github.com/isd-sgcu/rnkm65-file/src/mocks/cache"
+	fMock "github.com/isd-sgcu/rnkm65-file/src/mocks/file"
+	mock "github.com/isd-sgcu/rnkm65-file/src/mocks/gcs"
+	lMock "github.com/isd-sgcu/rnkm65-file/src/mocks/link"
+	sMock "github.com/isd-sgcu/rnkm65-file/src/mocks/share"
+	"github.com/isd-sgcu/rnkm65-file/src/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (t *GCSServiceTest) TestGetSignedUrlOwnerAllowed() {
+	t.authConf.Enabled = true
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(t.cacheFile, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "gateway", Subject: t.f.OwnerID})
+	actual, err := srv.GetSignedUrl(ctx, &proto.GetSignedUrlRequest{UserId: t.f.OwnerID})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), &proto.GetSignedUrlResponse{Url: t.url}, actual)
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlOtherUserDenied() {
+	t.authConf.Enabled = true
+
+	subject := faker.UUIDDigit()
+
+	shareRepo := sMock.RepositoryMock{}
+	shareRepo.On("FindGranted", []string{t.f.OwnerID}, subject, []string(nil)).Return([]share.Share{}, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &shareRepo, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "gateway", Trusted: true, Subject: subject})
+	actual, err := srv.GetSignedUrl(ctx, &proto.GetSignedUrlRequest{UserId: t.f.OwnerID})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.PermissionDenied, st.Code())
+	cacheRepo.AssertNotCalled(t.T(), "GetCache")
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlAdminAllowed() {
+	t.authConf.Enabled = true
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(t.cacheFile, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "gateway", Subject: faker.UUIDDigit(), IsAdmin: true})
+	actual, err := srv.GetSignedUrl(ctx, &proto.GetSignedUrlRequest{UserId: t.f.OwnerID})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), &proto.GetSignedUrlResponse{Url: t.url}, actual)
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlGrantedServiceAllowed() {
+	t.authConf.Enabled = true
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(t.cacheFile, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "checkin", Permissions: []auth.Permission{auth.Read}})
+	actual, err := srv.GetSignedUrl(ctx, &proto.GetSignedUrlRequest{UserId: t.f.OwnerID})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), &proto.GetSignedUrlResponse{Url: t.url}, actual)
+}
+
+func (t *GCSServiceTest) TestUploadReadOnlyServiceDenied() {
+	t.authConf.Enabled = true
+
+	c := mock.ClientMock{}
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "checkin", Permissions: []auth.Permission{auth.Read}})
+	actual, err := srv.Upload(ctx, &proto.UploadRequest{
+		Filename: t.filename,
+		Data:     t.file,
+		UserId:   t.f.OwnerID,
+		Tag:      1,
+		Type:     proto.FileType_FILE_TYPE_IMAGE,
+	})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.PermissionDenied, st.Code())
+	c.AssertNotCalled(t.T(), "Upload")
+}
+
+func (t *GCSServiceTest) TestUploadTrustedServiceCannotOverrideSubject() {
+	t.authConf.Enabled = true
+
+	c := mock.ClientMock{}
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "gateway", Trusted: true, Subject: faker.UUIDDigit()})
+	actual, err := srv.Upload(ctx, &proto.UploadRequest{
+		Filename: t.filename,
+		Data:     t.file,
+		UserId:   t.f.OwnerID,
+		Tag:      1,
+		Type:     proto.FileType_FILE_TYPE_IMAGE,
+	})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.PermissionDenied, st.Code())
+	c.AssertNotCalled(t.T(), "Upload")
+}
+
+func (t *GCSServiceTest) TestBatchGetSignedUrlsDeniedItems() {
+	t.authConf.Enabled = true
+
+	other := faker.UUIDDigit()
+
+	want := &proto.BatchGetSignedUrlsResponse{Results: []*proto.BatchGetSignedUrlsResult{
+		{UserId: t.f.OwnerID, Url: t.url},
+		{UserId: other, Code: int32(codes.PermissionDenied), Message: "Permission denied"},
+	}}
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetManyCache", []string{t.key}).Return([]*dto.CacheFile{t.cacheFile}, nil)
+
+	shareRepo := sMock.RepositoryMock{}
+	shareRepo.On("FindGranted", []string{other}, t.f.OwnerID, []string(nil)).Return([]share.Share{}, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &shareRepo, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "gateway", Subject: t.f.OwnerID})
+	actual, err := srv.BatchGetSignedUrls(ctx, &proto.BatchGetSignedUrlsRequest{
+		Items: []*proto.BatchGetSignedUrlsItem{{UserId: t.f.OwnerID}, {UserId: other}},
+	})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+}
+
+func (t *GCSServiceTest) TestFlushCacheNonAdminDenied() {
+	t.authConf.Enabled = true
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "gateway", Subject: t.f.OwnerID})
+	actual, err := srv.FlushCache(ctx, &proto.FlushCacheRequest{UserId: t.f.OwnerID})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.PermissionDenied, st.Code())
+	cacheRepo.AssertNotCalled(t.T(), "DeleteCacheByPattern")
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlPreferTokenSubject() {
+	t.authConf.Enabled = true
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(t.cacheFile, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "web", Subject: t.f.OwnerID})
+	actual, err := srv.GetSignedUrl(ctx, &proto.GetSignedUrlRequest{})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), &proto.GetSignedUrlResponse{Url: t.url}, actual)
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlTrustedServiceNamesUser() {
+	t.authConf.Enabled = true
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(t.cacheFile, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "gateway", Trusted: true})
+	actual, err := srv.GetSignedUrl(ctx, &proto.GetSignedUrlRequest{UserId: t.f.OwnerID})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), &proto.GetSignedUrlResponse{Url: t.url}, actual)
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlUntrustedServiceWithoutTokenDenied() {
+	t.authConf.Enabled = true
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "web"})
+	actual, err := srv.GetSignedUrl(ctx, &proto.GetSignedUrlRequest{UserId: t.f.OwnerID})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.PermissionDenied, st.Code())
+}
+
+func (t *GCSServiceTest) TestFlushCacheTrustedServiceDenied() {
+	t.authConf.Enabled = true
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "gateway", Trusted: true})
+	actual, err := srv.FlushCache(ctx, &proto.FlushCacheRequest{UserId: t.f.OwnerID})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.PermissionDenied, st.Code())
+}
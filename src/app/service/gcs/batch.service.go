@@ -0,0 +1,249 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/isd-sgcu/rnkm65-file/src/app/apperror"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
+	model "github.com/isd-sgcu/rnkm65-file/src/app/model/file"
+	"github.com/isd-sgcu/rnkm65-file/src/app/utils"
+	"github.com/isd-sgcu/rnkm65-file/src/constant/audit"
+	"github.com/isd-sgcu/rnkm65-file/src/metrics"
+	"github.com/isd-sgcu/rnkm65-file/src/proto"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"sync"
+)
+
+const (
+	maxBatchSize         = 500
+	batchSignConcurrency = 8
+)
+
+func (s *Service) BatchGetSignedUrls(ctx context.Context, req *proto.BatchGetSignedUrlsRequest) (*proto.BatchGetSignedUrlsResponse, error) {
+	if len(req.Items) == 0 {
+		return nil, apperror.InvalidArgument("items", "Items cannot be empty")
+	}
+
+	if len(req.Items) > maxBatchSize {
+		return nil, apperror.TooLarge("items", len(req.Items), maxBatchSize)
+	}
+
+	var ownerIds []string
+	seen := map[string]bool{}
+	for i, item := range req.Items {
+		if err := s.checkTag(fmt.Sprintf("items[%v].tag", i), item.Tag); err != nil {
+			return nil, err
+		}
+
+		if !seen[item.UserId] {
+			seen[item.UserId] = true
+			ownerIds = append(ownerIds, item.UserId)
+		}
+	}
+
+	denied, err := s.authorizeReadMany(ctx, "batch get signed urls", ownerIds)
+	if err != nil {
+		return nil, err
+	}
+
+	var userIds []string
+	for _, userId := range ownerIds {
+		if !denied[userId] {
+			userIds = append(userIds, userId)
+		}
+	}
+
+	cachedFiles, failed, err := s.batchLoad(ctx, userIds)
+	if err != nil {
+		return nil, err
+	}
+
+	recorded := map[string]bool{}
+	results := make([]*proto.BatchGetSignedUrlsResult, 0, len(req.Items))
+	for _, item := range req.Items {
+		result := &proto.BatchGetSignedUrlsResult{
+			UserId: item.UserId,
+			Tag:    item.Tag,
+		}
+
+		cachedFile, ok := cachedFiles[item.UserId]
+		switch {
+		case denied[item.UserId]:
+			result.Code = int32(codes.PermissionDenied)
+			result.Message = "Permission denied"
+		case failed[item.UserId] != nil:
+			result.Code = int32(codes.Unavailable)
+			result.Message = "Cannot connect to google cloud storage"
+		case !ok || (item.Tag != 0 && int(item.Tag) != cachedFile.Tag):
+			result.Code = int32(codes.NotFound)
+			result.Message = "Not found file"
+		default:
+			url, err := s.fileUrl(ctx, item.UserId, cachedFile)
+			if err != nil {
+				result.Code = int32(status.Code(err))
+				result.Message = status.Convert(err).Message()
+				break
+			}
+			result.Url = url
+		}
+
+		if !recorded[item.UserId] {
+			recorded[item.UserId] = true
+
+			var fileId string
+			if ok {
+				fileId = cachedFile.ID
+			}
+			s.record(ctx, audit.BATCH_GET_SIGNED_URLS, item.UserId, fileId, status.Error(codes.Code(result.Code), result.Message))
+		}
+
+		results = append(results, result)
+	}
+
+	return &proto.BatchGetSignedUrlsResponse{Results: results}, nil
+}
+
+// batchLoad reads the users' urls from the cache and signs the misses, the users without a file are left out
+func (s *Service) batchLoad(ctx context.Context, userIds []string) (map[string]*dto.CacheFile, map[string]error, error) {
+	cachedFiles := map[string]*dto.CacheFile{}
+	failed := map[string]error{}
+	if len(userIds) == 0 {
+		return cachedFiles, failed, nil
+	}
+
+	keys := make([]string, len(userIds))
+	values := make([]interface{}, len(userIds))
+	for i, userId := range userIds {
+		keys[i] = s.keys.File(userId)
+		values[i] = &dto.CacheFile{}
+	}
+
+	found, err := s.cacheRepo.GetManyCache(ctx, keys, values)
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "batch get signed urls").
+			Int("size", len(userIds)).
+			Msg("Error while connecting to redis server, fall through to database")
+		found = make([]bool, len(userIds))
+	}
+
+	var misses []string
+	for i, userId := range userIds {
+		cachedFile := values[i].(*dto.CacheFile)
+		if found[i] && cachedFile.NotFound {
+			metrics.CacheNegativeHits.Inc()
+			continue
+		}
+
+		if found[i] && s.isFresh(cachedFile) {
+			cachedFiles[userId] = cachedFile
+			continue
+		}
+
+		misses = append(misses, userId)
+	}
+
+	if len(misses) == 0 {
+		return cachedFiles, failed, nil
+	}
+
+	var files []model.File
+	err = s.repository.FindByOwnerIDs(ctx, misses, &files)
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "batch get signed urls").
+			Int("size", len(misses)).
+			Msg("Error while trying to query data")
+		return nil, nil, apperror.Unavailable(apperror.Database, err)
+	}
+
+	signed, errs := s.signFiles(ctx, files)
+	for userId, cachedFile := range signed {
+		cachedFiles[userId] = cachedFile
+	}
+	for userId, err := range errs {
+		failed[userId] = err
+	}
+
+	exists := map[string]bool{}
+	for _, f := range files {
+		exists[f.OwnerID] = true
+	}
+	for _, userId := range misses {
+		if !exists[userId] {
+			s.saveNotFound(ctx, userId)
+		}
+	}
+
+	return cachedFiles, failed, nil
+}
+
+// signFiles signs the urls concurrently and caches each of them, the result is keyed by the owner id
+func (s *Service) signFiles(ctx context.Context, files []model.File) (map[string]*dto.CacheFile, map[string]error) {
+	var (
+		mu     sync.Mutex
+		wg     sync.WaitGroup
+		signed = map[string]*dto.CacheFile{}
+		failed = map[string]error{}
+		sem    = make(chan struct{}, batchSignConcurrency)
+	)
+
+	for i := range files {
+		f := &files[i]
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			// a file that panicked is reported as failed instead of not found, the panic runs the recover first
+			defer func() {
+				mu.Lock()
+				if signed[f.OwnerID] == nil && failed[f.OwnerID] == nil {
+					failed[f.OwnerID] = errors.New("panic while signing the url")
+				}
+				mu.Unlock()
+			}()
+			defer utils.Recover("batch sign")
+
+			cachedFile, ttl, err := s.signUrl(ctx, f)
+			if err != nil {
+				log.Ctx(ctx).Error().
+					Err(err).
+					Str("module", "batch get signed urls").
+					Str("filename", f.Filename).
+					Str("user_id", f.OwnerID).
+					Msg("Cannot connect to google cloud storage")
+
+				mu.Lock()
+				failed[f.OwnerID] = err
+				mu.Unlock()
+				return
+			}
+
+			if err := s.cacheRepo.SaveCache(ctx, s.keys.File(f.OwnerID), cachedFile, ttl); err != nil {
+				log.Ctx(ctx).Error().
+					Err(err).
+					Str("module", "batch get signed urls").
+					Str("filename", f.Filename).
+					Str("user_id", f.OwnerID).
+					Msg("Error while connecting to redis server")
+			}
+
+			mu.Lock()
+			signed[f.OwnerID] = cachedFile
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	return signed, failed
+}
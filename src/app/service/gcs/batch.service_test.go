@@ -0,0 +1,200 @@
+package gcs
+
+import (
+	"context"
+	"github.com/bxcodec/faker/v3"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/file"
+	"github.com/isd-sgcu/rnkm65-file/src/app/utils"
+	cMock "github.com/isd-sgcu/rnkm65-file/src/mocks/cache"
+	fMock "github.com/isd-sgcu/rnkm65-file/src/mocks/file"
+	mock "github.com/isd-sgcu/rnkm65-file/src/mocks/gcs"
+	lMock "github.com/isd-sgcu/rnkm65-file/src/mocks/link"
+	sMock "github.com/isd-sgcu/rnkm65-file/src/mocks/share"
+	"github.com/isd-sgcu/rnkm65-file/src/proto"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+func (t *GCSServiceTest) TestBatchGetSignedUrlsSuccess() {
+	t.cacheFile.Tag = 1
+
+	missing := faker.UUIDDigit()
+	uncached := &file.File{
+		Filename: faker.Word(),
+		OwnerID:  faker.UUIDDigit(),
+		Tag:      2,
+	}
+
+	want := &proto.BatchGetSignedUrlsResponse{Results: []*proto.BatchGetSignedUrlsResult{
+		{UserId: t.f.OwnerID, Tag: 1, Url: t.url},
+		{UserId: uncached.OwnerID, Url: t.url},
+		{UserId: missing, Code: int32(codes.NotFound), Message: "Not found file"},
+		{UserId: t.f.OwnerID, Tag: 2, Code: int32(codes.NotFound), Message: "Not found file"},
+	}}
+
+	c := mock.ClientMock{}
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return(t.url, nil)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerIDs", []string{uncached.OwnerID, missing}).Return([]file.File{*uncached}, nil)
+
+	keys := utils.NewCacheKey("", 0)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetManyCache", []string{t.key, keys.File(uncached.OwnerID), keys.File(missing)}).Return([]*dto.CacheFile{t.cacheFile, nil, nil}, nil)
+	cacheRepo.On("SaveCache", keys.File(uncached.OwnerID), t.url, t.ttl).Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.BatchGetSignedUrls(context.Background(), &proto.BatchGetSignedUrlsRequest{
+		Items: []*proto.BatchGetSignedUrlsItem{
+			{UserId: t.f.OwnerID, Tag: 1},
+			{UserId: uncached.OwnerID},
+			{UserId: missing},
+			{UserId: t.f.OwnerID, Tag: 2},
+		},
+	})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+	c.AssertNumberOfCalls(t.T(), "GetSignedUrl", 1)
+}
+
+func (t *GCSServiceTest) TestBatchGetSignedUrlsNegativeCache() {
+	t.cacheConf.NegativeTTL = 30
+
+	missing := faker.UUIDDigit()
+	keys := utils.NewCacheKey("", 0)
+
+	want := &proto.BatchGetSignedUrlsResponse{Results: []*proto.BatchGetSignedUrlsResult{
+		{UserId: t.f.OwnerID, Code: int32(codes.NotFound), Message: "Not found file"},
+		{UserId: missing, Code: int32(codes.NotFound), Message: "Not found file"},
+	}}
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerIDs", []string{missing}).Return([]file.File{}, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetManyCache", []string{t.key, keys.File(missing)}).Return([]*dto.CacheFile{{NotFound: true}, nil}, nil)
+	cacheRepo.On("SaveCache", keys.File(missing), "", 30).Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.BatchGetSignedUrls(context.Background(), &proto.BatchGetSignedUrlsRequest{
+		Items: []*proto.BatchGetSignedUrlsItem{{UserId: t.f.OwnerID}, {UserId: missing}},
+	})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+	cacheRepo.AssertExpectations(t.T())
+}
+
+func (t *GCSServiceTest) TestBatchGetSignedUrlsSignFailed() {
+	t.cacheFile.ExpiresAt = time.Now()
+
+	want := &proto.BatchGetSignedUrlsResponse{Results: []*proto.BatchGetSignedUrlsResult{
+		{UserId: t.f.OwnerID, Code: int32(codes.Unavailable), Message: "Cannot connect to google cloud storage"},
+	}}
+
+	c := mock.ClientMock{}
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return("", t.err)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerIDs", []string{t.f.OwnerID}).Return([]file.File{*t.f}, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetManyCache", []string{t.key}).Return([]*dto.CacheFile{t.cacheFile}, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.BatchGetSignedUrls(context.Background(), &proto.BatchGetSignedUrlsRequest{
+		Items: []*proto.BatchGetSignedUrlsItem{{UserId: t.f.OwnerID}},
+	})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+}
+
+func (t *GCSServiceTest) TestBatchGetSignedUrlsSignPanic() {
+	t.cacheFile.ExpiresAt = time.Now()
+
+	want := &proto.BatchGetSignedUrlsResponse{Results: []*proto.BatchGetSignedUrlsResult{
+		{UserId: t.f.OwnerID, Code: int32(codes.Unavailable), Message: "Cannot connect to google cloud storage"},
+	}}
+
+	c := mock.ClientMock{}
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Panic("nil pointer dereference")
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerIDs", []string{t.f.OwnerID}).Return([]file.File{*t.f}, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetManyCache", []string{t.key}).Return([]*dto.CacheFile{t.cacheFile}, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.BatchGetSignedUrls(context.Background(), &proto.BatchGetSignedUrlsRequest{
+		Items: []*proto.BatchGetSignedUrlsItem{{UserId: t.f.OwnerID}},
+	})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+}
+
+func (t *GCSServiceTest) TestBatchGetSignedUrlsEmpty() {
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	actual, err := srv.BatchGetSignedUrls(context.Background(), &proto.BatchGetSignedUrlsRequest{})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.InvalidArgument, st.Code())
+}
+
+func (t *GCSServiceTest) TestBatchGetSignedUrlsUnknownTag() {
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	actual, err := srv.BatchGetSignedUrls(context.Background(), &proto.BatchGetSignedUrlsRequest{
+		Items: []*proto.BatchGetSignedUrlsItem{{UserId: t.f.OwnerID, Tag: 1}, {UserId: t.f.OwnerID, Tag: 9}},
+	})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.InvalidArgument, st.Code())
+
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), "items[1].tag", badRequest.FieldViolations[0].Field)
+}
+
+func (t *GCSServiceTest) TestBatchGetSignedUrlsQueryFailed() {
+	c := mock.ClientMock{}
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerIDs", []string{t.f.OwnerID}).Return(nil, errors.New("Cannot connect to database"))
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetManyCache", []string{t.key}).Return(nil, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.BatchGetSignedUrls(context.Background(), &proto.BatchGetSignedUrlsRequest{
+		Items: []*proto.BatchGetSignedUrlsItem{{UserId: t.f.OwnerID}},
+	})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.Unavailable, st.Code())
+}
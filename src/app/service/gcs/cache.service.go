@@ -0,0 +1,144 @@
+package gcs
+
+import (
+	"context"
+	"github.com/isd-sgcu/rnkm65-file/src/app/apperror"
+	"github.com/isd-sgcu/rnkm65-file/src/app/auth"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
+	model "github.com/isd-sgcu/rnkm65-file/src/app/model/file"
+	"github.com/isd-sgcu/rnkm65-file/src/app/utils"
+	"github.com/isd-sgcu/rnkm65-file/src/metrics"
+	"github.com/isd-sgcu/rnkm65-file/src/proto"
+	"github.com/rs/zerolog/log"
+)
+
+const flushBatchSize = 500
+
+func (s *Service) FlushCache(ctx context.Context, req *proto.FlushCacheRequest) (*proto.FlushCacheResponse, error) {
+	if err := s.authorize(ctx, "flush cache", auth.Admin, ""); err != nil {
+		return nil, err
+	}
+
+	if req.UserId != "" && req.Tag != 0 {
+		return nil, apperror.InvalidArgument("userId", "Flush either a user id or a tag, not both")
+	}
+
+	if err := s.checkTag("tag", req.Tag); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case req.UserId != "":
+		deleted, err := s.cacheRepo.DeleteCacheByPattern(ctx, s.keys.OwnerPattern(req.UserId))
+		if err != nil {
+			log.Ctx(ctx).Error().
+				Err(err).
+				Str("module", "flush cache").
+				Str("user_id", req.UserId).
+				Msg("Error while connecting to redis server")
+			return nil, apperror.Unavailable(apperror.Cache, err)
+		}
+
+		return &proto.FlushCacheResponse{Deleted: deleted}, nil
+
+	case req.Tag != 0:
+		var files []model.File
+		err := s.repository.FindByTag(ctx, int(req.Tag), &files)
+		if err != nil {
+			log.Ctx(ctx).Error().
+				Err(err).
+				Str("module", "flush cache").
+				Int32("tag", req.Tag).
+				Msg("Error while trying to query data")
+			return nil, apperror.Unavailable(apperror.Database, err)
+		}
+
+		var deleted int64
+		for start := 0; start < len(files); start += flushBatchSize {
+			end := start + flushBatchSize
+			if end > len(files) {
+				end = len(files)
+			}
+
+			var keys []string
+			for _, f := range files[start:end] {
+				keys = append(keys, s.keys.File(f.OwnerID))
+			}
+
+			n, err := s.cacheRepo.DeleteCache(ctx, keys...)
+			if err != nil {
+				log.Ctx(ctx).Error().
+					Err(err).
+					Str("module", "flush cache").
+					Int32("tag", req.Tag).
+					Msg("Error while connecting to redis server")
+				return nil, apperror.Unavailable(apperror.Cache, err)
+			}
+			deleted += n
+		}
+
+		return &proto.FlushCacheResponse{Deleted: deleted}, nil
+
+	default:
+		return nil, apperror.InvalidArgument("userId", "User id or tag is required")
+	}
+}
+
+// StartWarmCache warms the cache in the background, the job is counted before it starts so Wait never misses it
+func (s *Service) StartWarmCache(ctx context.Context, limit int) {
+	s.jobs.Add(1)
+	go func() {
+		defer s.jobs.Done()
+		defer utils.Recover("cache warm")
+
+		warmed, err := s.WarmCache(ctx, limit)
+		if err != nil {
+			log.Ctx(ctx).Error().
+				Err(err).
+				Str("module", "cache warm").
+				Msg("Failed to warm the cache")
+			return
+		}
+
+		log.Ctx(ctx).Info().
+			Str("module", "cache warm").
+			Msgf("Warmed %v signed urls", warmed)
+	}()
+}
+
+// WarmCache signs the urls of the most recently updated files before the traffic comes in,
+// the update time is used as the activity signal and the entries that are still fresh are skipped
+func (s *Service) WarmCache(ctx context.Context, limit int) (int, error) {
+	var files []model.File
+	err := s.repository.FindRecent(ctx, limit, &files)
+	if err != nil {
+		return 0, err
+	}
+
+	keys := make([]string, len(files))
+	values := make([]interface{}, len(files))
+	for i, f := range files {
+		keys[i] = s.keys.File(f.OwnerID)
+		values[i] = &dto.CacheFile{}
+	}
+
+	found, err := s.cacheRepo.GetManyCache(ctx, keys, values)
+	if err != nil {
+		found = make([]bool, len(files))
+	}
+
+	var stale []model.File
+	for i, f := range files {
+		cachedFile := values[i].(*dto.CacheFile)
+		if found[i] && !cachedFile.NotFound && s.isFresh(cachedFile) && !s.needsRefresh(cachedFile) {
+			continue
+		}
+
+		stale = append(stale, f)
+	}
+
+	signed, _ := s.signFiles(ctx, stale)
+	metrics.CacheRefreshes.WithLabelValues("warm").Add(float64(len(signed)))
+
+	return len(signed), nil
+}
@@ -0,0 +1,122 @@
+package gcs
+
+import (
+	"context"
+	"github.com/bxcodec/faker/v3"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/file"
+	"github.com/isd-sgcu/rnkm65-file/src/app/utils"
+	cMock "github.com/isd-sgcu/rnkm65-file/src/mocks/cache"
+	fMock "github.com/isd-sgcu/rnkm65-file/src/mocks/file"
+	mock "github.com/isd-sgcu/rnkm65-file/src/mocks/gcs"
+	lMock "github.com/isd-sgcu/rnkm65-file/src/mocks/link"
+	sMock "github.com/isd-sgcu/rnkm65-file/src/mocks/share"
+	"github.com/isd-sgcu/rnkm65-file/src/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (t *GCSServiceTest) TestFlushCacheByUser() {
+	want := &proto.FlushCacheResponse{Deleted: 2}
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("DeleteCacheByPattern", "rnkm65-file:*:file:owner:"+t.f.OwnerID).Return(int64(2), nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.FlushCache(context.Background(), &proto.FlushCacheRequest{UserId: t.f.OwnerID})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+}
+
+func (t *GCSServiceTest) TestFlushCacheByTag() {
+	other := file.File{OwnerID: faker.UUIDDigit()}
+	keys := utils.NewCacheKey("", 0)
+
+	want := &proto.FlushCacheResponse{Deleted: 1}
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByTag", 1).Return([]file.File{*t.f, other}, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("DeleteCache", []string{t.key, keys.File(other.OwnerID)}).Return(int64(1), nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.FlushCache(context.Background(), &proto.FlushCacheRequest{Tag: 1})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+}
+
+func (t *GCSServiceTest) TestFlushCacheInvalidArgument() {
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	for _, req := range []*proto.FlushCacheRequest{{}, {UserId: t.f.OwnerID, Tag: 1}} {
+		actual, err := srv.FlushCache(context.Background(), req)
+
+		st, ok := status.FromError(err)
+
+		assert.True(t.T(), ok)
+		assert.Nil(t.T(), actual)
+		assert.Equal(t.T(), codes.InvalidArgument, st.Code())
+	}
+}
+
+func (t *GCSServiceTest) TestWarmCache() {
+	t.cacheConf.RefreshWindow = 120
+
+	fresh := file.File{OwnerID: faker.UUIDDigit()}
+	keys := utils.NewCacheKey("", 0)
+
+	c := mock.ClientMock{}
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return(t.url, nil)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindRecent", 2).Return([]file.File{*t.f, fresh}, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetManyCache", []string{t.key, keys.File(fresh.OwnerID)}).Return([]*dto.CacheFile{nil, t.cacheFile}, nil)
+	cacheRepo.On("SaveCache", t.key, t.url, t.ttl).Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	warmed, err := srv.WarmCache(context.Background(), 2)
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), 1, warmed)
+	cacheRepo.AssertExpectations(t.T())
+}
+
+func (t *GCSServiceTest) TestStartWarmCacheIsWaited() {
+	c := mock.ClientMock{}
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return(t.url, nil)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindRecent", 1).Return([]file.File{*t.f}, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetManyCache", []string{t.key}).Return([]*dto.CacheFile{nil}, nil)
+	cacheRepo.On("SaveCache", t.key, t.url, t.ttl).Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	srv.StartWarmCache(context.Background(), 1)
+	srv.Wait()
+
+	cacheRepo.AssertCalled(t.T(), "SaveCache", t.key, t.url, t.ttl)
+}
+
+func (t *GCSServiceTest) TestStartWarmCacheRecoversPanic() {
+	repo := fMock.RepositoryMock{}
+	repo.On("FindRecent", 1).Panic("nil map")
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	srv.StartWarmCache(context.Background(), 1)
+	srv.Wait()
+
+	repo.AssertCalled(t.T(), "FindRecent", 1)
+}
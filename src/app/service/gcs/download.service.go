@@ -0,0 +1,135 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"github.com/go-redis/redis/v8"
+	"github.com/isd-sgcu/rnkm65-file/src/app/apperror"
+	"github.com/isd-sgcu/rnkm65-file/src/app/auth"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
+	model "github.com/isd-sgcu/rnkm65-file/src/app/model/file"
+	"github.com/isd-sgcu/rnkm65-file/src/app/utils"
+	"github.com/isd-sgcu/rnkm65-file/src/constant/audit"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"os"
+)
+
+const defaultDownloadTokenTTL = 300
+
+// fileUrl returns the url handed to the caller, the private files are served through the download proxy when it is enabled
+// so that the url stops working once the access is revoked or the file is replaced
+func (s *Service) fileUrl(ctx context.Context, userId string, cachedFile *dto.CacheFile) (string, error) {
+	if !s.conf.Proxy.Enabled || cachedFile.Public {
+		return cachedFile.Url, nil
+	}
+
+	token, err := newLinkToken()
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "download token").
+			Str("user_id", userId).
+			Msg("Cannot generate the download token")
+		return "", apperror.Internal(err)
+	}
+
+	downloadToken := &dto.DownloadToken{
+		OwnerID: userId,
+		FileID:  cachedFile.ID,
+		Version: cachedFile.Version,
+	}
+	if caller, ok := auth.FromContext(ctx); ok {
+		downloadToken.Caller = caller
+	}
+
+	err = s.cacheRepo.SaveCache(ctx, s.keys.Download(utils.Hash([]byte(token))), downloadToken, s.downloadTokenTTL())
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "download token").
+			Str("user_id", userId).
+			Msg("Error while connecting to redis server")
+		return "", apperror.Unavailable(apperror.Cache, err)
+	}
+
+	return s.conf.Proxy.BaseUrl + token, nil
+}
+
+// OpenDownload checks the download token against the current access and file, the caller has to close the object
+func (s *Service) OpenDownload(ctx context.Context, token string) (obj *dto.Object, err error) {
+	downloadToken := &dto.DownloadToken{}
+	defer func() { s.record(ctx, audit.OPEN_DOWNLOAD, downloadToken.OwnerID, downloadToken.FileID, err) }()
+
+	err = s.cacheRepo.GetCache(ctx, s.keys.Download(utils.Hash([]byte(token))), downloadToken)
+	if err != nil {
+		if err == redis.Nil {
+			return nil, apperror.NotFound("download", "")
+		}
+
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "open download").
+			Msg("Error while connecting to redis server")
+		return nil, apperror.Unavailable(apperror.Cache, err)
+	}
+
+	if downloadToken.Caller != nil {
+		ctx = auth.NewContext(ctx, downloadToken.Caller)
+	}
+
+	if err := s.authorizeRead(ctx, "open download", downloadToken.OwnerID); err != nil {
+		return nil, err
+	}
+
+	f := &model.File{}
+	err = s.repository.FindByOwnerID(ctx, downloadToken.OwnerID, f)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFound("file", downloadToken.OwnerID)
+		}
+
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "open download").
+			Str("user_id", downloadToken.OwnerID).
+			Msg("Error while trying to query data")
+		return nil, apperror.Unavailable(apperror.Database, err)
+	}
+
+	// the upload keeps the id of the replaced file and bumps its version
+	if downloadToken.FileID != "" && downloadToken.FileID != f.ID.String() ||
+		downloadToken.Version != 0 && downloadToken.Version != f.Version {
+		return nil, apperror.NotFound("file", downloadToken.OwnerID)
+	}
+
+	obj, err = s.client.Open(ctx, f.Filename, f.Public)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, apperror.NotFound("file", downloadToken.OwnerID)
+		}
+
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "open download").
+			Str("filename", f.Filename).
+			Str("user_id", downloadToken.OwnerID).
+			Msg("Cannot connect to google cloud storage")
+		return nil, apperror.Unavailable(apperror.Storage, err)
+	}
+
+	obj.Name = f.Name
+	if obj.Name == "" {
+		obj.Name = f.Filename
+	}
+
+	return obj, nil
+}
+
+func (s *Service) downloadTokenTTL() int {
+	if s.conf.Proxy.TokenTTL > 0 {
+		return s.conf.Proxy.TokenTTL
+	}
+
+	return defaultDownloadTokenTTL
+}
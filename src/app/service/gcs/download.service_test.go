@@ -0,0 +1,198 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/bxcodec/faker/v3"
+	"github.com/go-redis/redis/v8"
+	"github.com/isd-sgcu/rnkm65-file/src/app/auth"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/file"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/share"
+	"github.com/isd-sgcu/rnkm65-file/src/app/utils"
+	cMock "github.com/isd-sgcu/rnkm65-file/src/mocks/cache"
+	fMock "github.com/isd-sgcu/rnkm65-file/src/mocks/file"
+	mock "github.com/isd-sgcu/rnkm65-file/src/mocks/gcs"
+	lMock "github.com/isd-sgcu/rnkm65-file/src/mocks/link"
+	sMock "github.com/isd-sgcu/rnkm65-file/src/mocks/share"
+	"github.com/isd-sgcu/rnkm65-file/src/proto"
+	"github.com/stretchr/testify/assert"
+	tMock "github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"os"
+	"strings"
+)
+
+func (t *GCSServiceTest) enableProxy() {
+	t.conf.Proxy.Enabled = true
+	t.conf.Proxy.BaseUrl = "https://rnkm65.test/downloads/"
+	t.conf.Proxy.TokenTTL = 60
+}
+
+func (t *GCSServiceTest) downloadKey(token string) string {
+	return utils.NewCacheKey("", 0).Download(utils.Hash([]byte(token)))
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlIssuesDownloadToken() {
+	t.enableProxy()
+	t.cacheFile.ID = t.f.ID.String()
+	t.cacheFile.Version = 2
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(t.cacheFile, nil)
+	cacheRepo.On("SaveCache", tMock.Anything, tMock.Anything, 60).Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.GetSignedUrl(context.Background(), &proto.GetSignedUrlRequest{UserId: t.f.OwnerID})
+
+	assert.Nil(t.T(), err)
+	assert.True(t.T(), strings.HasPrefix(actual.Url, t.conf.Proxy.BaseUrl))
+
+	token := strings.TrimPrefix(actual.Url, t.conf.Proxy.BaseUrl)
+	assert.Equal(t.T(), &dto.DownloadToken{OwnerID: t.f.OwnerID, FileID: t.f.ID.String(), Version: 2}, cacheRepo.V[t.downloadKey(token)])
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlPublicSkipsProxy() {
+	t.enableProxy()
+	t.cacheFile.Public = true
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(t.cacheFile, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.GetSignedUrl(context.Background(), &proto.GetSignedUrlRequest{UserId: t.f.OwnerID})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), t.url, actual.Url)
+	cacheRepo.AssertNotCalled(t.T(), "SaveCache", tMock.Anything, tMock.Anything, tMock.Anything)
+}
+
+func (t *GCSServiceTest) TestOpenDownloadSuccess() {
+	t.f.Name = "photo.png"
+	want := &dto.Object{
+		ReadSeekCloser: nopCloser{bytes.NewReader(t.file)},
+		ContentType:    "image/png",
+		Size:           int64(len(t.file)),
+	}
+
+	c := mock.ClientMock{}
+	c.On("Open", t.filename, false).Return(want, nil)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil)
+
+	cacheRepo := cMock.RepositoryMock{}
+	cacheRepo.On("GetCache", t.downloadKey("token"), &dto.DownloadToken{}).Return(&dto.DownloadToken{OwnerID: t.f.OwnerID, FileID: t.f.ID.String()}, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.OpenDownload(context.Background(), "token")
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), "photo.png", actual.Name)
+
+	content, _ := io.ReadAll(actual)
+	assert.Equal(t.T(), t.file, content)
+}
+
+func (t *GCSServiceTest) TestOpenDownloadRevokedShare() {
+	t.authConf.Enabled = true
+	subject := faker.UUIDDigit()
+	caller := &auth.Caller{Service: "gateway", Trusted: true, Subject: subject}
+
+	shareRepo := sMock.RepositoryMock{}
+	shareRepo.On("FindGranted", []string{t.f.OwnerID}, subject, []string(nil)).Return([]share.Share{}, nil)
+
+	repo := fMock.RepositoryMock{}
+
+	cacheRepo := cMock.RepositoryMock{}
+	cacheRepo.On("GetCache", t.downloadKey("token"), &dto.DownloadToken{}).Return(&dto.DownloadToken{OwnerID: t.f.OwnerID, FileID: t.f.ID.String(), Caller: caller}, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &repo, &shareRepo, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.OpenDownload(context.Background(), "token")
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.PermissionDenied, st.Code())
+	repo.AssertNotCalled(t.T(), "FindByOwnerID", tMock.Anything, tMock.Anything)
+}
+
+func (t *GCSServiceTest) TestOpenDownloadReplacedFile() {
+	replaced := &file.File{
+		Base:     t.f.Base,
+		Filename: t.filename,
+		OwnerID:  t.f.OwnerID,
+		Version:  2,
+	}
+
+	c := mock.ClientMock{}
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(replaced, nil)
+
+	cacheRepo := cMock.RepositoryMock{}
+	cacheRepo.On("GetCache", t.downloadKey("token"), &dto.DownloadToken{}).Return(&dto.DownloadToken{OwnerID: t.f.OwnerID, FileID: t.f.ID.String(), Version: 1}, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.OpenDownload(context.Background(), "token")
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.NotFound, st.Code())
+	c.AssertNotCalled(t.T(), "Open", tMock.Anything, tMock.Anything)
+}
+
+func (t *GCSServiceTest) TestOpenDownloadMissingObject() {
+	c := mock.ClientMock{}
+	c.On("Open", t.filename, false).Return(nil, fmt.Errorf("%s: %w", t.filename, os.ErrNotExist))
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil)
+
+	cacheRepo := cMock.RepositoryMock{}
+	cacheRepo.On("GetCache", t.downloadKey("token"), &dto.DownloadToken{}).Return(&dto.DownloadToken{OwnerID: t.f.OwnerID, FileID: t.f.ID.String()}, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.OpenDownload(context.Background(), "token")
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.NotFound, st.Code())
+}
+
+func (t *GCSServiceTest) TestOpenDownloadExpiredToken() {
+	cacheRepo := cMock.RepositoryMock{}
+	cacheRepo.On("GetCache", t.downloadKey("token"), &dto.DownloadToken{}).Return(nil, redis.Nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.OpenDownload(context.Background(), "token")
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.NotFound, st.Code())
+}
+
+type nopCloser struct {
+	io.ReadSeeker
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -2,179 +2,719 @@ package gcs
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"github.com/go-redis/redis/v8"
+	"github.com/isd-sgcu/rnkm65-file/src/app/apperror"
+	"github.com/isd-sgcu/rnkm65-file/src/app/auth"
+	auditDto "github.com/isd-sgcu/rnkm65-file/src/app/dto/audit"
 	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
+	auditModel "github.com/isd-sgcu/rnkm65-file/src/app/model/audit"
 	model "github.com/isd-sgcu/rnkm65-file/src/app/model/file"
+	linkModel "github.com/isd-sgcu/rnkm65-file/src/app/model/link"
+	shareModel "github.com/isd-sgcu/rnkm65-file/src/app/model/share"
 	"github.com/isd-sgcu/rnkm65-file/src/app/utils"
 	"github.com/isd-sgcu/rnkm65-file/src/config"
+	"github.com/isd-sgcu/rnkm65-file/src/constant/audit"
 	"github.com/isd-sgcu/rnkm65-file/src/constant/file"
+	"github.com/isd-sgcu/rnkm65-file/src/metrics"
 	"github.com/isd-sgcu/rnkm65-file/src/proto"
 	"github.com/rs/zerolog/log"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultUrlExpiresIn = 15 * time.Minute
+	maxUrlExpiresIn     = 7 * 24 * time.Hour
+	lockPollInterval    = 50 * time.Millisecond
+	defaultPublicPrefix = "public/"
 )
 
 type Service struct {
 	conf       config.GCS
 	ttl        int
+	cacheConf  config.Cache
+	authConf   config.Auth
+	linkConf   config.Link
+	keys       *utils.CacheKey
 	client     IClient
 	repository IRepository
+	shareRepo  IShareRepository
+	linkRepo   ILinkRepository
 	cacheRepo  ICacheRepository
+	auditRepo  IAuditRepository
+	group      singleflight.Group
+	jobs       sync.WaitGroup
 }
 
 type IClient interface {
-	Upload([]byte, string) error
-	GetSignedUrl(string) (string, error)
+	Upload(context.Context, []byte, string) error
+	UploadPublic(context.Context, []byte, string) error
+	GetSignedUrl(context.Context, string, time.Duration) (string, error)
+	GetPublicUrl(string) string
+	Delete(context.Context, string, bool) error
+	Open(context.Context, string, bool) (*dto.Object, error)
 }
 
 type IRepository interface {
-	FindByOwnerID(string, *model.File) error
-	CreateOrUpdate(*model.File) error
+	FindByID(context.Context, string, *model.File) error
+	FindByOwnerID(context.Context, string, *model.File) error
+	FindByOwnerIDs(context.Context, []string, *[]model.File) error
+	FindByTag(context.Context, int, *[]model.File) error
+	FindRecent(context.Context, int, *[]model.File) error
+	CreateOrUpdate(context.Context, *model.File, int64) (*model.File, error)
+	Delete(context.Context, string, int64) error
+}
+
+type IShareRepository interface {
+	CreateOrUpdate(*shareModel.Share) error
+	Delete(string, string, string) error
+	FindSharedWith(string, []string, *[]shareModel.Share) error
+	FindGranted([]string, string, []string, *[]shareModel.Share) error
+}
+
+type ILinkRepository interface {
+	FindByID(string, *linkModel.Link) error
+	FindByTokenHash(string, *linkModel.Link) error
+	Create(*linkModel.Link) error
 	Delete(string) error
 }
 
+type IAuditRepository interface {
+	Create(*auditModel.Audit) error
+	Find(*auditDto.Filter, *[]auditModel.Audit) error
+}
+
 type ICacheRepository interface {
-	SaveCache(string, interface{}, int) error
-	GetCache(string, interface{}) error
+	SaveCache(context.Context, string, interface{}, int) error
+	GetCache(context.Context, string, interface{}) error
+	GetManyCache(context.Context, []string, []interface{}) ([]bool, error)
+	Increment(context.Context, string, int) (int64, error)
+	AcquireLock(context.Context, string, int) (string, error)
+	ReleaseLock(context.Context, string, string) error
+	DeleteCache(context.Context, ...string) (int64, error)
+	DeleteCacheByPattern(context.Context, string) (int64, error)
 }
 
-func NewService(conf config.GCS, ttl int, client IClient, repository IRepository, cacheRepo ICacheRepository) *Service {
+func NewService(conf config.GCS, ttl int, cacheConf config.Cache, authConf config.Auth, linkConf config.Link, client IClient, repository IRepository, shareRepo IShareRepository, linkRepo ILinkRepository, cacheRepo ICacheRepository, auditRepo IAuditRepository) *Service {
 	return &Service{
 		conf:       conf,
 		ttl:        ttl,
+		cacheConf:  cacheConf,
+		authConf:   authConf,
+		linkConf:   linkConf,
+		keys:       utils.NewCacheKey(cacheConf.Prefix, cacheConf.Version),
 		client:     client,
 		repository: repository,
+		shareRepo:  shareRepo,
+		linkRepo:   linkRepo,
 		cacheRepo:  cacheRepo,
+		auditRepo:  auditRepo,
 	}
 }
 
-func (s *Service) Upload(_ context.Context, req *proto.UploadRequest) (*proto.UploadResponse, error) {
+func (s *Service) Upload(ctx context.Context, req *proto.UploadRequest) (res *proto.UploadResponse, err error) {
+	userId := s.userId(ctx, req.UserId)
+	var fileId string
+	defer func() { s.record(ctx, audit.UPLOAD, userId, fileId, err) }()
+
+	if err := s.authorize(ctx, "upload image", auth.Write, userId); err != nil {
+		return nil, err
+	}
+
 	if req.Data == nil {
-		return nil, status.Error(codes.InvalidArgument, "File cannot be empty")
+		return nil, apperror.InvalidArgument("data", "File cannot be empty")
+	}
+
+	if err := checkType(req.Type); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkTag("tag", req.Tag); err != nil {
+		return nil, err
+	}
+
+	key, err := idempotencyKey(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if key != "" && s.cacheConf.IdempotencyTTL > 0 {
+		res, err = s.uploadOnce(ctx, userId, key, req)
+	} else {
+		res, _, err = s.upload(ctx, userId, req)
+	}
+	if err != nil {
+		return nil, err
+	}
+	fileId = res.Id
+
+	return res, nil
+}
+
+func (s *Service) upload(ctx context.Context, userId string, req *proto.UploadRequest) (*proto.UploadResponse, *model.File, error) {
+	if req.IfMatch > 0 {
+		if err := s.checkVersion(ctx, "upload image", userId, req.IfMatch); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	filename, err := utils.GetObjectName(req.Filename, s.conf.Secret, file.Type(req.Type))
 	if err != nil {
-		log.Error().Err(err).
+		log.Ctx(ctx).Error().Err(err).
 			Str("service", "file").
 			Str("module", "upload image").
 			Str("file_name", filename).
 			Msg("Invalid file type")
-		return nil, status.Error(codes.InvalidArgument, "Invalid file type")
+		return nil, nil, apperror.InvalidType("type", "Invalid file type")
 	}
 
-	err = s.client.Upload(req.Data, filename)
+	public := req.Public || s.isPublicTag(int(req.Tag))
+	if public {
+		filename = s.publicPrefix() + filename
+		err = s.client.UploadPublic(ctx, req.Data, filename)
+	} else {
+		err = s.client.Upload(ctx, req.Data, filename)
+	}
 	if err != nil {
-		log.Error().
+		log.Ctx(ctx).Error().
 			Err(err).
 			Str("module", "upload image").
 			Msg("Cannot connect to google cloud storage")
-		return nil, status.Error(codes.Unavailable, "Cannot connect to google cloud storage")
+		return nil, nil, apperror.Unavailable(apperror.Storage, err)
 	}
 
 	f := &model.File{
 		Filename: filename,
-		OwnerID:  req.UserId,
+		Name:     req.Filename,
+		OwnerID:  userId,
 		Tag:      int(req.Tag),
+		Type:     int(req.Type),
+		Public:   public,
 	}
 
-	err = s.repository.CreateOrUpdate(f)
+	previous, err := s.repository.CreateOrUpdate(ctx, f, req.IfMatch)
+	if errors.Is(err, apperror.ErrVersionMismatch) {
+		s.deleteObject(ctx, "upload image", filename, public)
+		return nil, nil, apperror.VersionMismatch("file", userId)
+	}
 
 	if err != nil {
-		log.Error().
+		log.Ctx(ctx).Error().
 			Err(err).
 			Str("module", "upload image").
 			Str("filename", filename).
-			Str("user_id", req.UserId).
+			Str("user_id", userId).
 			Msg("Error while saving file data")
-		return nil, status.Error(codes.Unavailable, "Internal service error")
+		return nil, nil, apperror.Unavailable(apperror.Database, err)
 	}
 
-	url, err := s.client.GetSignedUrl(filename)
+	cacheFile, ttl, err := s.signUrl(ctx, f)
 	if err != nil {
-		log.Error().
+		log.Ctx(ctx).Error().
 			Err(err).
 			Str("module", "upload image").
 			Str("filename", filename).
-			Str("user_id", req.UserId).
+			Str("user_id", userId).
 			Msg("Error while trying to get signed url")
-		return nil, status.Error(codes.Unavailable, "Internal service error")
-	}
-
-	cacheFile := dto.CacheFile{
-		Url:      url,
-		Filename: filename,
+		return nil, nil, apperror.Unavailable(apperror.Storage, err)
 	}
 
-	err = s.cacheRepo.SaveCache(req.UserId, &cacheFile, s.ttl)
+	err = s.cacheRepo.SaveCache(ctx, s.keys.File(userId), cacheFile, ttl)
 	if err != nil {
-		log.Error().
+		log.Ctx(ctx).Error().
 			Err(err).
 			Str("module", "upload image").
 			Str("filename", filename).
-			Str("user_id", req.UserId).
+			Str("user_id", userId).
 			Interface("cache", cacheFile).
-			Msg("Error while connecting to redis server")
-		return nil, status.Error(codes.Unavailable, "Error while connecting to redis server")
+			Msg("Error while connecting to redis server, the file is saved without cache")
+
+		// a not found entry cached before this upload would hide the file until it expires
+		if _, err := s.cacheRepo.DeleteCache(ctx, s.keys.File(userId)); err != nil {
+			log.Ctx(ctx).Error().
+				Err(err).
+				Str("module", "upload image").
+				Str("user_id", userId).
+				Msg("Cannot delete the cached file, a cached not found expires after the negative ttl")
+		}
 	}
 
-	return &proto.UploadResponse{Url: url}, nil
+	// the replaced object would stay readable, a public one at its permanent url
+	if previous != nil && (previous.Filename != f.Filename || previous.Public != f.Public) {
+		s.deleteObject(ctx, "upload image", previous.Filename, previous.Public)
+	}
+
+	metrics.UploadBytes.
+		WithLabelValues(strconv.Itoa(int(req.Tag)), file.Type(req.Type).String()).
+		Observe(float64(len(req.Data)))
+
+	url, err := s.fileUrl(ctx, userId, cacheFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &proto.UploadResponse{Url: url, Id: f.ID.String(), Version: f.Version}, f, nil
 }
 
-func (s *Service) GetSignedUrl(_ context.Context, req *proto.GetSignedUrlRequest) (*proto.GetSignedUrlResponse, error) {
+func (s *Service) GetSignedUrl(ctx context.Context, req *proto.GetSignedUrlRequest) (res *proto.GetSignedUrlResponse, err error) {
+	userId := s.userId(ctx, req.UserId)
+	var fileId string
+	defer func() { s.record(ctx, audit.GET_SIGNED_URL, userId, fileId, err) }()
+
+	if err := s.authorizeRead(ctx, "get signed url", userId); err != nil {
+		return nil, err
+	}
+
 	cachedFile := &dto.CacheFile{}
-	err := s.cacheRepo.GetCache(req.UserId, cachedFile)
-	if err == nil {
-		return &proto.GetSignedUrlResponse{Url: cachedFile.Url}, nil
+	err = s.cacheRepo.GetCache(ctx, s.keys.File(userId), cachedFile)
+	switch {
+	case err == nil && (cachedFile.NotFound || s.isFresh(cachedFile)):
+		metrics.CacheLookups.WithLabelValues("hit").Inc()
+	case err == nil || err == redis.Nil:
+		metrics.CacheLookups.WithLabelValues("miss").Inc()
+	default:
+		metrics.CacheLookups.WithLabelValues("error").Inc()
+	}
+
+	if err == nil && cachedFile.NotFound {
+		metrics.CacheNegativeHits.Inc()
+		return nil, apperror.NotFound("file", userId)
+	}
+
+	if err == nil && s.isFresh(cachedFile) {
+		if s.needsRefresh(cachedFile) {
+			s.refresh(ctx, userId)
+		}
+
+		fileId = cachedFile.ID
+		url, err := s.fileUrl(ctx, userId, cachedFile)
+		if err != nil {
+			return nil, err
+		}
+
+		return &proto.GetSignedUrlResponse{Url: url, Version: cachedFile.Version}, nil
 	}
 
-	if err != redis.Nil {
-		log.Error().
+	if err != nil && err != redis.Nil {
+		log.Ctx(ctx).Error().
 			Err(err).
 			Str("module", "get signed url").
-			Str("user_id", req.UserId).
-			Msg("Error while connecting to redis server")
-		return nil, status.Error(codes.Unavailable, "Error while connecting to redis server")
+			Str("user_id", userId).
+			Msg("Error while connecting to redis server, fall through to database")
+	}
+
+	v, err, shared := s.group.Do(userId, func() (interface{}, error) {
+		return s.loadSignedUrl(detach(ctx), userId)
+	})
+	if shared {
+		metrics.CacheCoalesced.WithLabelValues("local").Inc()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cachedFile = v.(*dto.CacheFile)
+	fileId = cachedFile.ID
+	url, err := s.fileUrl(ctx, userId, cachedFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proto.GetSignedUrlResponse{Url: url, Version: cachedFile.Version}, nil
+}
+
+// Delete removes the file of the user with its object and cached url, the share links of the file stop resolving.
+// The row goes first so a failed or lost delete never leaves a row pointing to a missing object
+func (s *Service) Delete(ctx context.Context, req *proto.DeleteRequest) (res *proto.DeleteResponse, err error) {
+	userId := s.userId(ctx, req.UserId)
+	var fileId string
+	defer func() { s.record(ctx, audit.DELETE, userId, fileId, err) }()
+
+	if err := s.authorize(ctx, "delete file", auth.Write, userId); err != nil {
+		return nil, err
+	}
+
+	f := &model.File{}
+	err = s.repository.FindByOwnerID(ctx, userId, f)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFound("file", userId)
+		}
+
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "delete file").
+			Str("user_id", userId).
+			Msg("Error while trying to query data")
+		return nil, apperror.Unavailable(apperror.Database, err)
+	}
+	fileId = f.ID.String()
+
+	if req.IfMatch > 0 && req.IfMatch != f.Version {
+		return nil, apperror.VersionMismatch("file", userId)
+	}
+
+	// the row is only deleted at the version read above, a file replaced in the meantime keeps its new object
+	version := req.IfMatch
+	if version == 0 {
+		version = f.Version
+	}
+
+	err = s.repository.Delete(ctx, fileId, version)
+	if errors.Is(err, apperror.ErrVersionMismatch) {
+		if req.IfMatch > 0 {
+			return nil, apperror.VersionMismatch("file", userId)
+		}
+		return nil, apperror.Aborted("FILE_REPLACED", "The file was replaced while deleting")
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// deleted by a concurrent call which also deletes the object
+	case err != nil:
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "delete file").
+			Str("filename", f.Filename).
+			Str("user_id", userId).
+			Msg("Error while deleting file data")
+		return nil, apperror.Unavailable(apperror.Database, err)
+	default:
+		s.deleteObject(ctx, "delete file", f.Filename, f.Public)
+	}
+
+	if _, err := s.cacheRepo.DeleteCache(ctx, s.keys.File(userId)); err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "delete file").
+			Str("user_id", userId).
+			Msg("Error while connecting to redis server, the cached url lives until it expires")
+	}
+
+	return &proto.DeleteResponse{}, nil
+}
+
+// checkVersion fails fast before anything is written when the file is not at the if match version,
+// the write itself is still conditional on the version
+func (s *Service) checkVersion(ctx context.Context, module string, userId string, version int64) error {
+	f := &model.File{}
+	err := s.repository.FindByOwnerID(ctx, userId, f)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apperror.VersionMismatch("file", userId)
+	}
+
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", module).
+			Str("user_id", userId).
+			Msg("Error while trying to query data")
+		return apperror.Unavailable(apperror.Database, err)
+	}
+
+	if f.Version != version {
+		return apperror.VersionMismatch("file", userId)
+	}
+
+	return nil
+}
+
+// deleteObject removes the object that no row points to, a failure only leaves an orphan object behind
+func (s *Service) deleteObject(ctx context.Context, module string, filename string, public bool) {
+	if err := s.client.Delete(ctx, filename, public); err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", module).
+			Str("filename", filename).
+			Msg("Cannot delete the orphan object from google cloud storage")
+	}
+}
+
+// loadSignedUrl queries the file, signs its url and caches it, only one call per user runs at a time in this process
+func (s *Service) loadSignedUrl(ctx context.Context, userId string) (*dto.CacheFile, error) {
+	if s.cacheConf.LockTTL > 0 {
+		lockKey := s.keys.Lock(userId)
+
+		token, err := s.cacheRepo.AcquireLock(ctx, lockKey, s.cacheConf.LockTTL)
+		if err != nil {
+			log.Ctx(ctx).Warn().
+				Err(err).
+				Str("module", "get signed url").
+				Str("user_id", userId).
+				Msg("Cannot acquire cache lock, loading without it")
+		}
+
+		if err == nil && token == "" {
+			if cachedFile, ok := s.waitForCache(ctx, userId); ok {
+				metrics.CacheCoalesced.WithLabelValues("remote").Inc()
+				if cachedFile.NotFound {
+					return nil, apperror.NotFound("file", userId)
+				}
+				return cachedFile, nil
+			}
+		}
+
+		if token != "" {
+			defer func() {
+				if err := s.cacheRepo.ReleaseLock(ctx, lockKey, token); err != nil {
+					log.Ctx(ctx).Warn().
+						Err(err).
+						Str("module", "get signed url").
+						Str("user_id", userId).
+						Msg("Cannot release cache lock")
+				}
+			}()
+		}
 	}
 
 	f := model.File{}
-	err = s.repository.FindByOwnerID(req.UserId, &f)
+	err := s.repository.FindByOwnerID(ctx, userId, &f)
 	if err != nil {
-		log.Error().
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			s.saveNotFound(ctx, userId)
+			return nil, apperror.NotFound("file", userId)
+		}
+
+		log.Ctx(ctx).Error().
 			Err(err).
 			Str("module", "get signed url").
-			Str("user_id", req.UserId).
+			Str("user_id", userId).
 			Msg("Error while trying to query data")
-		return nil, status.Error(codes.NotFound, "Not found file")
+		return nil, apperror.Unavailable(apperror.Database, err)
 	}
 
-	url, err := s.client.GetSignedUrl(f.Filename)
+	cachedFile, ttl, err := s.signUrl(ctx, &f)
 	if err != nil {
-		log.Error().
+		log.Ctx(ctx).Error().
 			Err(err).
-			Str("module", "upload image").
-			Str("filename", cachedFile.Filename).
-			Str("user_id", req.UserId).
+			Str("module", "get signed url").
+			Str("filename", f.Filename).
+			Str("user_id", userId).
 			Msg("Cannot connect to google cloud storage")
-		return nil, status.Error(codes.Unavailable, "Cannot connect to google cloud storage")
+		return nil, apperror.Unavailable(apperror.Storage, err)
 	}
 
-	cachedFile = &dto.CacheFile{
-		Url:      url,
-		Filename: f.Filename,
-	}
-
-	err = s.cacheRepo.SaveCache(req.UserId, cachedFile, s.ttl)
+	err = s.cacheRepo.SaveCache(ctx, s.keys.File(userId), cachedFile, ttl)
 	if err != nil {
-		log.Error().
+		log.Ctx(ctx).Error().
 			Err(err).
-			Str("module", "upload file").
+			Str("module", "get signed url").
 			Str("filename", cachedFile.Filename).
-			Str("user_id", req.UserId).
+			Str("user_id", userId).
 			Interface("cache", cachedFile).
 			Msg("Error while connecting to redis server")
-		return nil, status.Error(codes.Unavailable, "Error while connecting to redis server")
 	}
 
-	return &proto.GetSignedUrlResponse{Url: url}, nil
+	return cachedFile, nil
+}
+
+// waitForCache polls the cache while another replica holds the lock, it gives up once the lock would have expired
+func (s *Service) waitForCache(ctx context.Context, userId string) (*dto.CacheFile, bool) {
+	deadline := time.Now().Add(time.Duration(s.cacheConf.LockTTL) * time.Second)
+
+	for time.Now().Before(deadline) {
+		time.Sleep(lockPollInterval)
+
+		cachedFile := &dto.CacheFile{}
+		if err := s.cacheRepo.GetCache(ctx, s.keys.File(userId), cachedFile); err == nil && (cachedFile.NotFound || s.isFresh(cachedFile)) {
+			return cachedFile, true
+		}
+	}
+
+	return nil, false
+}
+
+// Wait blocks until the background refresh jobs are done
+func (s *Service) Wait() {
+	s.jobs.Wait()
+}
+
+// refresh re-signs the url in the background, it joins the in-flight lookup of the same user if there is one
+func (s *Service) refresh(ctx context.Context, userId string) {
+	ctx = detach(ctx)
+
+	s.jobs.Add(1)
+	go func() {
+		defer s.jobs.Done()
+		defer utils.Recover("cache refresh")
+
+		_, err, _ := s.group.Do(userId, func() (interface{}, error) {
+			return s.loadSignedUrl(ctx, userId)
+		})
+		if err == nil {
+			metrics.CacheRefreshes.WithLabelValues("background").Inc()
+		}
+	}()
+}
+
+// detach keeps the trace and the logger of the request but not its cancellation, the shared and background
+// lookups must not fail because the caller that started them has gone away
+func detach(ctx context.Context) context.Context {
+	return log.Ctx(ctx).WithContext(trace.ContextWithSpanContext(context.Background(), trace.SpanContextFromContext(ctx)))
+}
+
+// saveNotFound remembers that the user has no file for a short time, the next upload overwrites it
+func (s *Service) saveNotFound(ctx context.Context, userId string) {
+	if s.cacheConf.NegativeTTL <= 0 {
+		return
+	}
+
+	err := s.cacheRepo.SaveCache(ctx, s.keys.File(userId), &dto.CacheFile{NotFound: true}, s.cacheConf.NegativeTTL)
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "get signed url").
+			Str("user_id", userId).
+			Msg("Error while connecting to redis server")
+	}
+}
+
+// signUrl returns the url of the file with the ttl to cache it for, the public files get their permanent url without signing
+func (s *Service) signUrl(ctx context.Context, f *model.File) (*dto.CacheFile, int, error) {
+	if f.Public {
+		return &dto.CacheFile{
+			ID:       f.ID.String(),
+			Url:      s.client.GetPublicUrl(f.Filename),
+			Filename: f.Filename,
+			Tag:      f.Tag,
+			Public:   true,
+			Version:  f.Version,
+		}, s.ttl, nil
+	}
+
+	expiresIn := s.urlExpiresIn(f)
+	url, err := s.client.GetSignedUrl(ctx, f.Filename, expiresIn)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return &dto.CacheFile{
+		ID:        f.ID.String(),
+		Url:       url,
+		Filename:  f.Filename,
+		Tag:       f.Tag,
+		ExpiresAt: time.Now().Add(expiresIn),
+		Version:   f.Version,
+	}, s.cacheTTL(expiresIn), nil
+}
+
+// checkType rejects the file types that have no object name, including the unspecified type
+func checkType(fileType proto.FileType) error {
+	if file.Type(fileType).String() != "" {
+		return nil
+	}
+
+	var names []string
+	for value, name := range proto.FileType_name {
+		if file.Type(value).String() != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return apperror.InvalidType("type", fmt.Sprintf("Unknown file type %v, expected one of %v", fileType, strings.Join(names, ", ")))
+}
+
+// checkTag rejects the tags that are not in the tag registry, zero is an untagged file and an empty registry
+// accepts any tag
+func (s *Service) checkTag(field string, tag int32) error {
+	if _, ok := s.conf.Tags[int(tag)]; ok || tag == 0 || len(s.conf.Tags) == 0 {
+		return nil
+	}
+
+	var ids []int
+	for id := range s.conf.Tags {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	registered := make([]string, len(ids))
+	for i, id := range ids {
+		registered[i] = fmt.Sprintf("%v (%v)", id, s.conf.Tags[id])
+	}
+
+	return apperror.InvalidType(field, fmt.Sprintf("Unknown tag %v, expected one of %v", tag, strings.Join(registered, ", ")))
+}
+
+func (s *Service) isPublicTag(tag int) bool {
+	for _, t := range s.conf.Public.Tags {
+		if t == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Service) publicPrefix() string {
+	if s.conf.Public.Prefix != "" {
+		return s.conf.Public.Prefix
+	}
+
+	return defaultPublicPrefix
+}
+
+func (s *Service) urlExpiresIn(f *model.File) time.Duration {
+	expiresIn := defaultUrlExpiresIn
+	if s.conf.UrlExpiry.Default > 0 {
+		expiresIn = time.Duration(s.conf.UrlExpiry.Default) * time.Second
+	}
+
+	if sec, ok := s.conf.UrlExpiry.Types[file.Type(f.Type).String()]; ok && sec > 0 {
+		expiresIn = time.Duration(sec) * time.Second
+	}
+
+	if sec, ok := s.conf.UrlExpiry.Tags[f.Tag]; ok && sec > 0 {
+		expiresIn = time.Duration(sec) * time.Second
+	}
+
+	if expiresIn > maxUrlExpiresIn {
+		expiresIn = maxUrlExpiresIn
+	}
+
+	return expiresIn
+}
+
+func (s *Service) safetyMargin() time.Duration {
+	return time.Duration(s.conf.UrlExpiry.SafetyMargin) * time.Second
+}
+
+// isFresh reports whether the cached url still has more than the safety margin left before it expires,
+// the public urls never expire
+func (s *Service) isFresh(cachedFile *dto.CacheFile) bool {
+	return cachedFile.Public || time.Until(cachedFile.ExpiresAt) > s.safetyMargin()
+}
+
+// needsRefresh reports whether the cached url is inside the refresh window, it is still served but re-signed in the background
+func (s *Service) needsRefresh(cachedFile *dto.CacheFile) bool {
+	if s.cacheConf.RefreshWindow <= 0 || cachedFile.Public {
+		return false
+	}
+
+	window := s.safetyMargin() + time.Duration(s.cacheConf.RefreshWindow)*time.Second
+
+	return time.Until(cachedFile.ExpiresAt) <= window
+}
+
+// cacheTTL keeps the cache entry from outliving the url it holds
+func (s *Service) cacheTTL(expiresIn time.Duration) int {
+	ttl := s.ttl
+
+	if limit := int((expiresIn - s.safetyMargin()) / time.Second); limit < ttl {
+		ttl = limit
+	}
+
+	if ttl < 1 {
+		ttl = 1
+	}
+
+	return ttl
 }
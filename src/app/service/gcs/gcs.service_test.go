@@ -5,20 +5,33 @@ import (
 	"fmt"
 	"github.com/bxcodec/faker/v3"
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/isd-sgcu/rnkm65-file/src/app/apperror"
 	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model"
 	"github.com/isd-sgcu/rnkm65-file/src/app/model/file"
+	"github.com/isd-sgcu/rnkm65-file/src/app/utils"
 	"github.com/isd-sgcu/rnkm65-file/src/config"
+	"github.com/isd-sgcu/rnkm65-file/src/metrics"
 	cMock "github.com/isd-sgcu/rnkm65-file/src/mocks/cache"
 	fMock "github.com/isd-sgcu/rnkm65-file/src/mocks/file"
 	mock "github.com/isd-sgcu/rnkm65-file/src/mocks/gcs"
+	lMock "github.com/isd-sgcu/rnkm65-file/src/mocks/link"
+	sMock "github.com/isd-sgcu/rnkm65-file/src/mocks/share"
 	"github.com/isd-sgcu/rnkm65-file/src/proto"
 	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	tMock "github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 type GCSServiceTest struct {
@@ -30,7 +43,12 @@ type GCSServiceTest struct {
 	err       error
 	f         *file.File
 	ttl       int
+	cacheConf config.Cache
+	authConf  config.Auth
+	linkConf  config.Link
 	cacheFile *dto.CacheFile
+	key       string
+	lockKey   string
 }
 
 func TestGCSService(t *testing.T) {
@@ -47,9 +65,11 @@ func (t *GCSServiceTest) SetupTest() {
 		Secret:              faker.Word(),
 		ServiceAccountKey:   []byte(faker.Word()),
 		ServiceAccountEmail: faker.Word(),
+		Tags:                config.Tags{1: "profile", 2: "document", 3: "banner"},
 	}
 
 	t.f = &file.File{
+		Base:     model.Base{ID: uuid.New()},
 		Filename: t.filename,
 		OwnerID:  faker.UUIDDigit(),
 		Tag:      1,
@@ -61,56 +81,120 @@ func (t *GCSServiceTest) SetupTest() {
 
 	t.ttl = 15 * 60
 
+	t.cacheConf = config.Cache{}
+
+	t.authConf = config.Auth{}
+
+	t.linkConf = config.Link{BaseUrl: "https://rnkm65.test/links/"}
+
+	t.key = utils.NewCacheKey("", 0).File(t.f.OwnerID)
+	t.lockKey = utils.NewCacheKey("", 0).Lock(t.f.OwnerID)
+
 	t.cacheFile = &dto.CacheFile{
-		Url:      t.url,
-		Filename: t.filename,
+		Url:       t.url,
+		Filename:  t.filename,
+		ExpiresAt: time.Now().Add(15 * time.Minute),
 	}
 }
 
 func (t *GCSServiceTest) TestUploadSuccess() {
-	want := &proto.UploadResponse{Url: t.url}
+	want := &proto.UploadResponse{Url: t.url, Id: t.f.ID.String()}
 
 	c := mock.ClientMock{}
 	c.On("Upload", t.file).Return(nil)
-	c.On("GetSignedUrl").Return(t.url, nil)
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return(t.url, nil)
 
 	repo := fMock.RepositoryMock{}
-	repo.On("CreateOrUpdate", t.f.OwnerID).Return(t.f, nil)
+	repo.On("CreateOrUpdate", t.f.OwnerID, int64(0)).Return(t.f, nil, nil)
 
 	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
-	cacheRepo.On("SaveCache", t.f.OwnerID, t.cacheFile.Url, t.ttl).Return(nil)
+	cacheRepo.On("SaveCache", t.key, t.cacheFile.Url, t.ttl).Return(nil)
 
-	srv := NewService(t.conf, t.ttl, &c, &repo, &cacheRepo)
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
 
 	actual, err := srv.Upload(context.Background(), &proto.UploadRequest{
 		Filename: t.filename,
 		Data:     t.file,
 		UserId:   t.f.OwnerID,
 		Tag:      1,
+		Type:     proto.FileType_FILE_TYPE_IMAGE,
 	})
 
 	assert.Nil(t.T(), err)
 	assert.Equal(t.T(), want, actual)
 }
 
+func (t *GCSServiceTest) TestUploadSaveCacheFailed() {
+	want := &proto.UploadResponse{Url: t.url, Id: t.f.ID.String()}
+
+	c := mock.ClientMock{}
+	c.On("Upload", t.file).Return(nil)
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return(t.url, nil)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("CreateOrUpdate", t.f.OwnerID, int64(0)).Return(t.f, nil, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("SaveCache", t.key, t.cacheFile.Url, t.ttl).Return(errors.New("Cannot connect to redis server"))
+	cacheRepo.On("DeleteCache", []string{t.key}).Return(int64(1), nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.Upload(context.Background(), &proto.UploadRequest{
+		Filename: t.filename,
+		Data:     t.file,
+		UserId:   t.f.OwnerID,
+		Tag:      1,
+		Type:     proto.FileType_FILE_TYPE_IMAGE,
+	})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+	cacheRepo.AssertCalled(t.T(), "DeleteCache", []string{t.key})
+}
+
+func (t *GCSServiceTest) TestUploadEmptyFile() {
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	actual, err := srv.Upload(context.Background(), &proto.UploadRequest{
+		Filename: t.filename,
+		UserId:   t.f.OwnerID,
+		Tag:      1,
+		Type:     proto.FileType_FILE_TYPE_IMAGE,
+	})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.InvalidArgument, st.Code())
+	assert.Len(t.T(), st.Details(), 1)
+
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), "data", badRequest.FieldViolations[0].Field)
+}
+
 func (t *GCSServiceTest) TestUploadFailed() {
 	c := mock.ClientMock{}
 	c.On("Upload", t.file).Return(errors.New("Cannot upload file"))
-	c.On("GetSignedUrl").Return(t.url, nil)
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return(t.url, nil)
 
 	repo := fMock.RepositoryMock{}
-	repo.On("CreateOrUpdate", t.f.OwnerID).Return(t.f, nil)
+	repo.On("CreateOrUpdate", t.f.OwnerID, int64(0)).Return(t.f, nil, nil)
 
 	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
-	cacheRepo.On("SaveCache", t.f.OwnerID, t.cacheFile.Url, t.ttl).Return(nil)
+	cacheRepo.On("SaveCache", t.key, t.cacheFile.Url, t.ttl).Return(nil)
 
-	srv := NewService(t.conf, t.ttl, &c, &repo, &cacheRepo)
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
 
 	actual, err := srv.Upload(context.Background(), &proto.UploadRequest{
 		Filename: t.filename,
 		Data:     t.file,
 		UserId:   t.f.OwnerID,
 		Tag:      1,
+		Type:     proto.FileType_FILE_TYPE_IMAGE,
 	})
 
 	st, ok := status.FromError(err)
@@ -132,9 +216,11 @@ func (t *GCSServiceTest) TestGetSignedUrlCachedSuccess() {
 	repo := fMock.RepositoryMock{}
 
 	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
-	cacheRepo.On("GetCache", t.f.OwnerID, &dto.CacheFile{}).Return(t.cacheFile, nil)
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(t.cacheFile, nil)
 
-	srv := NewService(t.conf, t.ttl, &c, &repo, &cacheRepo)
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	lookups := testutil.ToFloat64(metrics.CacheLookups.WithLabelValues("hit"))
 
 	actual, err := srv.GetSignedUrl(context.Background(), &proto.GetSignedUrlRequest{
 		UserId: t.f.OwnerID,
@@ -142,42 +228,193 @@ func (t *GCSServiceTest) TestGetSignedUrlCachedSuccess() {
 
 	assert.Nil(t.T(), err)
 	assert.Equal(t.T(), want, actual)
+	assert.Equal(t.T(), lookups+1, testutil.ToFloat64(metrics.CacheLookups.WithLabelValues("hit")))
 }
-func (t *GCSServiceTest) TestGetSignedUrlCachedErr() {
+
+func (t *GCSServiceTest) TestGetSignedUrlCachedNearExpiry() {
+	t.conf.UrlExpiry.SafetyMargin = 60
+	t.cacheFile.ExpiresAt = time.Now().Add(30 * time.Second)
+
+	newUrl := faker.URL()
+	want := &proto.GetSignedUrlResponse{Url: newUrl}
+
 	c := mock.ClientMock{}
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return(newUrl, nil)
 
 	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil)
 
 	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
-	cacheRepo.On("GetCache", t.f.OwnerID, &dto.CacheFile{}).Return(nil, errors.New("Cannot connect to redis server"))
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(t.cacheFile, nil)
+	cacheRepo.On("SaveCache", t.key, newUrl, t.ttl-60).Return(nil)
 
-	srv := NewService(t.conf, t.ttl, &c, &repo, &cacheRepo)
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
 
 	actual, err := srv.GetSignedUrl(context.Background(), &proto.GetSignedUrlRequest{
 		UserId: t.f.OwnerID,
 	})
 
-	st, ok := status.FromError(err)
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+	cacheRepo.AssertExpectations(t.T())
+}
 
-	assert.True(t.T(), ok)
-	assert.Nil(t.T(), actual)
-	assert.Equal(t.T(), codes.Unavailable, st.Code())
+func (t *GCSServiceTest) TestGetSignedUrlCacheTTLCappedByUrlExpiry() {
+	t.conf.UrlExpiry.Tags = map[int]int{t.f.Tag: 300}
+
+	want := &proto.GetSignedUrlResponse{Url: t.url}
+
+	c := mock.ClientMock{}
+	c.On("GetSignedUrl", 300*time.Second).Return(t.url, nil)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(nil, redis.Nil)
+	cacheRepo.On("SaveCache", t.key, t.url, 300).Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.GetSignedUrl(context.Background(), &proto.GetSignedUrlRequest{
+		UserId: t.f.OwnerID,
+	})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+	cacheRepo.AssertExpectations(t.T())
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlCoalesceConcurrentMiss() {
+	want := &proto.GetSignedUrlResponse{Url: t.url}
+
+	c := mock.ClientMock{}
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return(t.url, nil)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil).After(100 * time.Millisecond)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(nil, redis.Nil)
+	cacheRepo.On("SaveCache", t.key, t.cacheFile.Url, t.ttl).Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			actual, err := srv.GetSignedUrl(context.Background(), &proto.GetSignedUrlRequest{
+				UserId: t.f.OwnerID,
+			})
+
+			assert.Nil(t.T(), err)
+			assert.Equal(t.T(), want, actual)
+		}()
+	}
+	wg.Wait()
+
+	repo.AssertNumberOfCalls(t.T(), "FindByOwnerID", 1)
+	c.AssertNumberOfCalls(t.T(), "GetSignedUrl", 1)
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlWaitForLockHolder() {
+	t.cacheConf.LockTTL = 1
+
+	want := &proto.GetSignedUrlResponse{Url: t.url}
+
+	c := mock.ClientMock{}
+
+	repo := fMock.RepositoryMock{}
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(nil, redis.Nil).Once()
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(t.cacheFile, nil)
+	cacheRepo.On("AcquireLock", t.lockKey, 1).Return("", nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.GetSignedUrl(context.Background(), &proto.GetSignedUrlRequest{
+		UserId: t.f.OwnerID,
+	})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+	repo.AssertNotCalled(t.T(), "FindByOwnerID", t.f.OwnerID, &file.File{})
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlAcquireLockSuccess() {
+	t.cacheConf.LockTTL = 1
+
+	want := &proto.GetSignedUrlResponse{Url: t.url}
+
+	c := mock.ClientMock{}
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return(t.url, nil)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(nil, redis.Nil)
+	cacheRepo.On("AcquireLock", t.lockKey, 1).Return("token", nil)
+	cacheRepo.On("SaveCache", t.key, t.cacheFile.Url, t.ttl).Return(nil)
+	cacheRepo.On("ReleaseLock", t.lockKey, "token").Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.GetSignedUrl(context.Background(), &proto.GetSignedUrlRequest{
+		UserId: t.f.OwnerID,
+	})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+	cacheRepo.AssertExpectations(t.T())
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlCachedErr() {
+	want := &proto.GetSignedUrlResponse{Url: t.url}
+
+	c := mock.ClientMock{}
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return(t.url, nil)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(nil, errors.New("Cannot connect to redis server"))
+	cacheRepo.On("SaveCache", t.key, t.cacheFile.Url, t.ttl).Return(errors.New("Cannot connect to redis server"))
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	lookups := testutil.ToFloat64(metrics.CacheLookups.WithLabelValues("error"))
+
+	actual, err := srv.GetSignedUrl(context.Background(), &proto.GetSignedUrlRequest{
+		UserId: t.f.OwnerID,
+	})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+	assert.Equal(t.T(), lookups+1, testutil.ToFloat64(metrics.CacheLookups.WithLabelValues("error")))
 }
 
 func (t *GCSServiceTest) TestGetSignedUrlSuccessSaveCacheSuccess() {
 	want := &proto.GetSignedUrlResponse{Url: t.url}
 
 	c := mock.ClientMock{}
-	c.On("GetSignedUrl").Return(t.url, nil)
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return(t.url, nil)
 
 	repo := fMock.RepositoryMock{}
 	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil)
 
 	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
-	cacheRepo.On("GetCache", t.f.OwnerID, &dto.CacheFile{}).Return(nil, redis.Nil)
-	cacheRepo.On("SaveCache", t.f.OwnerID, t.cacheFile.Url, t.ttl).Return(nil)
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(nil, redis.Nil)
+	cacheRepo.On("SaveCache", t.key, t.cacheFile.Url, t.ttl).Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
 
-	srv := NewService(t.conf, t.ttl, &c, &repo, &cacheRepo)
+	lookups := testutil.ToFloat64(metrics.CacheLookups.WithLabelValues("miss"))
 
 	actual, err := srv.GetSignedUrl(context.Background(), &proto.GetSignedUrlRequest{
 		UserId: t.f.OwnerID,
@@ -185,20 +422,42 @@ func (t *GCSServiceTest) TestGetSignedUrlSuccessSaveCacheSuccess() {
 
 	assert.Nil(t.T(), err)
 	assert.Equal(t.T(), want, actual)
+	assert.Equal(t.T(), lookups+1, testutil.ToFloat64(metrics.CacheLookups.WithLabelValues("miss")))
 }
 
 func (t *GCSServiceTest) TestGetSignedUrlSuccessSaveCacheFailed() {
+	want := &proto.GetSignedUrlResponse{Url: t.url}
+
 	c := mock.ClientMock{}
-	c.On("GetSignedUrl").Return(t.url, nil)
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return(t.url, nil)
 
 	repo := fMock.RepositoryMock{}
 	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil)
 
 	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
-	cacheRepo.On("GetCache", t.f.OwnerID, &dto.CacheFile{}).Return(nil, redis.Nil)
-	cacheRepo.On("SaveCache", t.f.OwnerID, t.cacheFile.Url, t.ttl).Return(errors.New("Cannot connect to redis server"))
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(nil, redis.Nil)
+	cacheRepo.On("SaveCache", t.key, t.cacheFile.Url, t.ttl).Return(errors.New("Cannot connect to redis server"))
 
-	srv := NewService(t.conf, t.ttl, &c, &repo, &cacheRepo)
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.GetSignedUrl(context.Background(), &proto.GetSignedUrlRequest{
+		UserId: t.f.OwnerID,
+	})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlFailed() {
+	c := mock.ClientMock{}
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return("", t.err)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(nil, redis.Nil)
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
 
 	actual, err := srv.GetSignedUrl(context.Background(), &proto.GetSignedUrlRequest{
 		UserId: t.f.OwnerID,
@@ -211,16 +470,45 @@ func (t *GCSServiceTest) TestGetSignedUrlSuccessSaveCacheFailed() {
 	assert.Equal(t.T(), codes.Unavailable, st.Code())
 }
 
-func (t *GCSServiceTest) TestGetSignedUrlFailed() {
+func (t *GCSServiceTest) TestGetSignedUrlNotFound() {
 	c := mock.ClientMock{}
-	c.On("GetSignedUrl").Return("", t.err)
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return("", t.err)
 
 	repo := fMock.RepositoryMock{}
-	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil)
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(nil, gorm.ErrRecordNotFound)
 
 	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
-	cacheRepo.On("GetCache", t.f.OwnerID, &dto.CacheFile{}).Return(nil, redis.Nil)
-	srv := NewService(t.conf, t.ttl, &c, &repo, &cacheRepo)
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(nil, redis.Nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.GetSignedUrl(context.Background(), &proto.GetSignedUrlRequest{
+		UserId: t.f.OwnerID,
+	})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.NotFound, st.Code())
+	assert.Len(t.T(), st.Details(), 1)
+
+	info, ok := st.Details()[0].(*errdetails.ResourceInfo)
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), t.f.OwnerID, info.ResourceName)
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlDatabaseUnavailable() {
+	c := mock.ClientMock{}
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(nil, errors.New("Cannot connect to database"))
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(nil, redis.Nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
 
 	actual, err := srv.GetSignedUrl(context.Background(), &proto.GetSignedUrlRequest{
 		UserId: t.f.OwnerID,
@@ -231,27 +519,498 @@ func (t *GCSServiceTest) TestGetSignedUrlFailed() {
 	assert.True(t.T(), ok)
 	assert.Nil(t.T(), actual)
 	assert.Equal(t.T(), codes.Unavailable, st.Code())
+	assert.Equal(t.T(), "The database is unavailable", st.Message())
+	assert.Len(t.T(), st.Details(), 1)
+	assert.IsType(t.T(), &errdetails.RetryInfo{}, st.Details()[0])
+	assert.Empty(t.T(), cacheRepo.V)
 }
 
-func (t *GCSServiceTest) TestGetSignedUrlNotFound() {
+func (t *GCSServiceTest) TestGetSignedUrlNotFoundSaveNegativeCache() {
+	t.cacheConf.NegativeTTL = 30
+
+	c := mock.ClientMock{}
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(nil, gorm.ErrRecordNotFound)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(nil, redis.Nil)
+	cacheRepo.On("SaveCache", t.key, "", 30).Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.GetSignedUrl(context.Background(), &proto.GetSignedUrlRequest{
+		UserId: t.f.OwnerID,
+	})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.NotFound, st.Code())
+	assert.Equal(t.T(), &dto.CacheFile{NotFound: true}, cacheRepo.V[t.key])
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlNegativeCacheHit() {
+	c := mock.ClientMock{}
+
+	repo := fMock.RepositoryMock{}
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(&dto.CacheFile{NotFound: true}, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.GetSignedUrl(context.Background(), &proto.GetSignedUrlRequest{
+		UserId: t.f.OwnerID,
+	})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.NotFound, st.Code())
+	repo.AssertNotCalled(t.T(), "FindByOwnerID", t.f.OwnerID, &file.File{})
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlRefreshAhead() {
+	t.cacheConf.RefreshWindow = 120
+	t.cacheFile.ExpiresAt = time.Now().Add(time.Minute)
+
+	newUrl := faker.URL()
+	want := &proto.GetSignedUrlResponse{Url: t.url}
+
 	c := mock.ClientMock{}
-	c.On("GetSignedUrl").Return("", t.err)
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return(newUrl, nil)
 
 	repo := fMock.RepositoryMock{}
-	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(nil, errors.New("Not found file"))
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil)
 
 	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
-	cacheRepo.On("GetCache", t.f.OwnerID, &dto.CacheFile{}).Return(nil, redis.Nil)
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(t.cacheFile, nil)
+	cacheRepo.On("SaveCache", t.key, newUrl, t.ttl).Return(nil)
 
-	srv := NewService(t.conf, t.ttl, &c, &repo, &cacheRepo)
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
 
 	actual, err := srv.GetSignedUrl(context.Background(), &proto.GetSignedUrlRequest{
 		UserId: t.f.OwnerID,
 	})
+	srv.Wait()
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+	cacheRepo.AssertExpectations(t.T())
+}
+
+func (t *GCSServiceTest) TestUploadPublic() {
+	t.f.Public = true
+
+	want := &proto.UploadResponse{Url: t.url, Id: t.f.ID.String()}
+
+	c := mock.ClientMock{}
+	c.On("UploadPublic", t.file, tMock.MatchedBy(func(filename string) bool {
+		return strings.HasPrefix(filename, "public/file-")
+	})).Return(nil)
+	c.On("GetPublicUrl").Return(t.url)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("CreateOrUpdate", t.f.OwnerID, int64(0)).Return(t.f, nil, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("SaveCache", t.key, t.url, t.ttl).Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.Upload(context.Background(), &proto.UploadRequest{
+		Filename: t.filename,
+		Data:     t.file,
+		UserId:   t.f.OwnerID,
+		Tag:      1,
+		Type:     1,
+		Public:   true,
+	})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+	c.AssertNotCalled(t.T(), "Upload", t.file)
+	c.AssertNotCalled(t.T(), "GetSignedUrl", tMock.Anything)
+}
+
+func (t *GCSServiceTest) TestUploadPublicTag() {
+	t.conf.Public = config.Public{Prefix: "banner/", Tags: []int{3}}
+	t.f.Public = true
+
+	c := mock.ClientMock{}
+	c.On("UploadPublic", t.file, tMock.MatchedBy(func(filename string) bool {
+		return strings.HasPrefix(filename, "banner/image-")
+	})).Return(nil)
+	c.On("GetPublicUrl").Return(t.url)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("CreateOrUpdate", t.f.OwnerID, int64(0)).Return(t.f, nil, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("SaveCache", t.key, t.url, t.ttl).Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.Upload(context.Background(), &proto.UploadRequest{
+		Filename: t.filename,
+		Data:     t.file,
+		UserId:   t.f.OwnerID,
+		Tag:      3,
+		Type:     2,
+	})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), &proto.UploadResponse{Url: t.url, Id: t.f.ID.String()}, actual)
+	c.AssertExpectations(t.T())
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlPublicWithoutSigning() {
+	t.f.Public = true
+
+	c := mock.ClientMock{}
+	c.On("GetPublicUrl").Return(t.url)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(nil, redis.Nil)
+	cacheRepo.On("SaveCache", t.key, t.url, t.ttl).Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.GetSignedUrl(context.Background(), &proto.GetSignedUrlRequest{UserId: t.f.OwnerID})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), &proto.GetSignedUrlResponse{Url: t.url}, actual)
+	c.AssertNotCalled(t.T(), "GetSignedUrl", tMock.Anything)
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlCachedPublicNeverExpires() {
+	t.cacheConf.RefreshWindow = 120
+	t.cacheFile.Public = true
+	t.cacheFile.ExpiresAt = time.Time{}
+
+	c := mock.ClientMock{}
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(t.cacheFile, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.GetSignedUrl(context.Background(), &proto.GetSignedUrlRequest{UserId: t.f.OwnerID})
+	srv.Wait()
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), &proto.GetSignedUrlResponse{Url: t.url}, actual)
+	c.AssertNotCalled(t.T(), "GetSignedUrl", tMock.Anything)
+}
+
+func (t *GCSServiceTest) TestDeleteSuccess() {
+	c := mock.ClientMock{}
+	c.On("Delete", t.filename, false).Return(nil)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil)
+	repo.On("Delete", t.f.ID.String(), int64(0)).Return(nil)
+
+	cacheRepo := cMock.RepositoryMock{}
+	cacheRepo.On("DeleteCache", []string{t.key}).Return(int64(1), nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.Delete(context.Background(), &proto.DeleteRequest{UserId: t.f.OwnerID})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), &proto.DeleteResponse{}, actual)
+	repo.AssertExpectations(t.T())
+	cacheRepo.AssertExpectations(t.T())
+}
+
+func (t *GCSServiceTest) TestDeleteNotFound() {
+	c := mock.ClientMock{}
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(nil, gorm.ErrRecordNotFound)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	actual, err := srv.Delete(context.Background(), &proto.DeleteRequest{UserId: t.f.OwnerID})
 
 	st, ok := status.FromError(err)
 
 	assert.True(t.T(), ok)
 	assert.Nil(t.T(), actual)
 	assert.Equal(t.T(), codes.NotFound, st.Code())
+	c.AssertNotCalled(t.T(), "Delete", t.filename, false)
+}
+
+func (t *GCSServiceTest) TestDeleteGCSError() {
+	c := mock.ClientMock{}
+	c.On("Delete", t.filename, false).Return(t.err)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil)
+	repo.On("Delete", t.f.ID.String(), int64(0)).Return(nil)
+
+	cacheRepo := cMock.RepositoryMock{}
+	cacheRepo.On("DeleteCache", []string{t.key}).Return(int64(1), nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.Delete(context.Background(), &proto.DeleteRequest{UserId: t.f.OwnerID})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), &proto.DeleteResponse{}, actual)
+	repo.AssertExpectations(t.T())
+}
+
+func (t *GCSServiceTest) TestDeleteReplacedWhileDeleting() {
+	t.f.Version = 2
+
+	c := mock.ClientMock{}
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil)
+	repo.On("Delete", t.f.ID.String(), int64(2)).Return(apperror.ErrVersionMismatch)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	actual, err := srv.Delete(context.Background(), &proto.DeleteRequest{UserId: t.f.OwnerID})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.Aborted, st.Code())
+	c.AssertNotCalled(t.T(), "Delete", tMock.Anything, tMock.Anything)
+}
+
+func (t *GCSServiceTest) TestUploadIfMatchMismatch() {
+	t.f.Version = 3
+
+	c := mock.ClientMock{}
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	actual, err := srv.Upload(context.Background(), &proto.UploadRequest{
+		Filename: t.filename,
+		Data:     t.file,
+		UserId:   t.f.OwnerID,
+		Tag:      1,
+		IfMatch:  2,
+		Type:     proto.FileType_FILE_TYPE_IMAGE,
+	})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.FailedPrecondition, st.Code())
+	assert.Len(t.T(), st.Details(), 1)
+	assert.IsType(t.T(), &errdetails.PreconditionFailure{}, st.Details()[0])
+	c.AssertNotCalled(t.T(), "Upload", tMock.Anything)
+}
+
+func (t *GCSServiceTest) TestUploadIfMatchLostRace() {
+	t.f.Version = 2
+
+	c := mock.ClientMock{}
+	c.On("Upload", t.file).Return(nil)
+	c.On("Delete", tMock.Anything, false).Return(nil)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil)
+	repo.On("CreateOrUpdate", t.f.OwnerID, int64(2)).Return(nil, nil, apperror.ErrVersionMismatch)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	actual, err := srv.Upload(context.Background(), &proto.UploadRequest{
+		Filename: t.filename,
+		Data:     t.file,
+		UserId:   t.f.OwnerID,
+		Tag:      1,
+		IfMatch:  2,
+		Type:     proto.FileType_FILE_TYPE_IMAGE,
+	})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.FailedPrecondition, st.Code())
+	c.AssertCalled(t.T(), "Delete", tMock.Anything, false)
+}
+
+func (t *GCSServiceTest) TestUploadReturnsVersion() {
+	t.f.Version = 4
+	want := &proto.UploadResponse{Url: t.url, Id: t.f.ID.String(), Version: 4}
+
+	c := mock.ClientMock{}
+	c.On("Upload", t.file).Return(nil)
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return(t.url, nil)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(&file.File{Base: t.f.Base, OwnerID: t.f.OwnerID, Version: 3}, nil)
+	repo.On("CreateOrUpdate", t.f.OwnerID, int64(3)).Return(t.f, nil, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("SaveCache", t.key, t.cacheFile.Url, t.ttl).Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.Upload(context.Background(), &proto.UploadRequest{
+		Filename: t.filename,
+		Data:     t.file,
+		UserId:   t.f.OwnerID,
+		Tag:      1,
+		IfMatch:  3,
+		Type:     proto.FileType_FILE_TYPE_IMAGE,
+	})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+	assert.Equal(t.T(), int64(4), cacheRepo.V[t.key].(*dto.CacheFile).Version)
+}
+
+func (t *GCSServiceTest) TestUploadDeletesReplacedObject() {
+	previous := &file.File{Base: t.f.Base, Filename: "banner/" + t.filename, OwnerID: t.f.OwnerID, Public: true}
+
+	c := mock.ClientMock{}
+	c.On("Upload", t.file).Return(nil)
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return(t.url, nil)
+	c.On("Delete", previous.Filename, true).Return(nil)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("CreateOrUpdate", t.f.OwnerID, int64(0)).Return(t.f, previous, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("SaveCache", t.key, t.cacheFile.Url, t.ttl).Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	_, err := srv.Upload(context.Background(), &proto.UploadRequest{
+		Filename: t.filename,
+		Data:     t.file,
+		UserId:   t.f.OwnerID,
+		Tag:      1,
+		Type:     proto.FileType_FILE_TYPE_IMAGE,
+	})
+
+	assert.Nil(t.T(), err)
+	c.AssertCalled(t.T(), "Delete", previous.Filename, true)
+}
+
+func (t *GCSServiceTest) TestUploadKeepsSameObject() {
+	c := mock.ClientMock{}
+	c.On("Upload", t.file).Return(nil)
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return(t.url, nil)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("CreateOrUpdate", t.f.OwnerID, int64(0)).Return(t.f, t.f, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("SaveCache", t.key, t.cacheFile.Url, t.ttl).Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	_, err := srv.Upload(context.Background(), &proto.UploadRequest{
+		Filename: t.filename,
+		Data:     t.file,
+		UserId:   t.f.OwnerID,
+		Tag:      1,
+		Type:     proto.FileType_FILE_TYPE_IMAGE,
+	})
+
+	assert.Nil(t.T(), err)
+	c.AssertNotCalled(t.T(), "Delete", tMock.Anything, tMock.Anything)
+}
+
+func (t *GCSServiceTest) TestDeleteIfMatchMismatch() {
+	t.f.Version = 3
+
+	c := mock.ClientMock{}
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	actual, err := srv.Delete(context.Background(), &proto.DeleteRequest{UserId: t.f.OwnerID, IfMatch: 2})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.FailedPrecondition, st.Code())
+	c.AssertNotCalled(t.T(), "Delete", tMock.Anything, tMock.Anything)
+	repo.AssertNotCalled(t.T(), "Delete", tMock.Anything, tMock.Anything)
+}
+
+func (t *GCSServiceTest) TestUploadUnspecifiedType() {
+	c := mock.ClientMock{}
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	actual, err := srv.Upload(context.Background(), &proto.UploadRequest{
+		Filename: t.filename,
+		Data:     t.file,
+		UserId:   t.f.OwnerID,
+		Tag:      1,
+	})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.InvalidArgument, st.Code())
+	assert.Equal(t.T(), "Unknown file type FILE_TYPE_UNSPECIFIED, expected one of FILE_TYPE_FILE, FILE_TYPE_IMAGE", st.Message())
+
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), "type", badRequest.FieldViolations[0].Field)
+	c.AssertNotCalled(t.T(), "Upload", tMock.Anything)
+}
+
+func (t *GCSServiceTest) TestUploadUnknownTag() {
+	c := mock.ClientMock{}
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	actual, err := srv.Upload(context.Background(), &proto.UploadRequest{
+		Filename: t.filename,
+		Data:     t.file,
+		UserId:   t.f.OwnerID,
+		Tag:      7,
+		Type:     proto.FileType_FILE_TYPE_FILE,
+	})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.InvalidArgument, st.Code())
+	assert.Equal(t.T(), "Unknown tag 7, expected one of 1 (profile), 2 (document), 3 (banner)", st.Message())
+	assert.ErrorIs(t.T(), err, apperror.ErrInvalidType)
+
+	badRequest, ok := st.Details()[0].(*errdetails.BadRequest)
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), "tag", badRequest.FieldViolations[0].Field)
+	c.AssertNotCalled(t.T(), "Upload", tMock.Anything)
+}
+
+func (t *GCSServiceTest) TestCheckTagEmptyRegistry() {
+	t.conf.Tags = nil
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	assert.Nil(t.T(), srv.checkTag("tag", 7))
 }
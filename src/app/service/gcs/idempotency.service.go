@@ -0,0 +1,178 @@
+package gcs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"github.com/go-redis/redis/v8"
+	"github.com/isd-sgcu/rnkm65-file/src/app/apperror"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
+	model "github.com/isd-sgcu/rnkm65-file/src/app/model/file"
+	"github.com/isd-sgcu/rnkm65-file/src/app/utils"
+	"github.com/isd-sgcu/rnkm65-file/src/proto"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	IdempotencyKeyHeader      = "idempotency-key"
+	maxIdempotencyKeyLength   = 255
+	defaultIdempotencyLockTTL = 60
+)
+
+// uploadOnce runs the upload once per idempotency key of the owner, the retries get the response of the first
+// upload while it is kept and are aborted while the first upload is still in progress
+func (s *Service) uploadOnce(ctx context.Context, userId string, key string, req *proto.UploadRequest) (*proto.UploadResponse, error) {
+	keyHash := utils.Hash([]byte(key))
+	resultKey := s.keys.Idempotency(userId, keyHash)
+	fingerprint := uploadFingerprint(req)
+
+	res, err := s.replayUpload(ctx, userId, resultKey, fingerprint)
+	if res != nil || err != nil {
+		return res, err
+	}
+
+	lockTTL := s.cacheConf.IdempotencyLockTTL
+	if lockTTL <= 0 {
+		lockTTL = defaultIdempotencyLockTTL
+	}
+
+	lockKey := s.keys.IdempotencyLock(userId, keyHash)
+	token, err := s.cacheRepo.AcquireLock(ctx, lockKey, lockTTL)
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "upload image").
+			Str("user_id", userId).
+			Msg("Error while connecting to redis server")
+		return nil, apperror.Unavailable(apperror.Cache, err)
+	}
+
+	if token == "" {
+		return nil, apperror.Aborted("IDEMPOTENCY_KEY_IN_USE", "The upload with the same idempotency key is in progress")
+	}
+
+	defer func() {
+		if err := s.cacheRepo.ReleaseLock(ctx, lockKey, token); err != nil {
+			log.Ctx(ctx).Error().
+				Err(err).
+				Str("module", "upload image").
+				Str("user_id", userId).
+				Msg("Cannot release the idempotency lock, it expires after its ttl")
+		}
+	}()
+
+	// the first upload may have finished between the lookup and the lock
+	res, err = s.replayUpload(ctx, userId, resultKey, fingerprint)
+	if res != nil || err != nil {
+		return res, err
+	}
+
+	res, f, err := s.upload(ctx, userId, req)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &dto.IdempotentUpload{
+		Fingerprint: fingerprint,
+		ID:          res.Id,
+		Version:     res.Version,
+		Filename:    f.Filename,
+		Tag:         f.Tag,
+		Public:      f.Public,
+	}
+
+	err = s.cacheRepo.SaveCache(ctx, resultKey, result, s.cacheConf.IdempotencyTTL)
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "upload image").
+			Str("user_id", userId).
+			Msg("Error while connecting to redis server, a retry of this upload will upload again")
+	}
+
+	return res, nil
+}
+
+// replayUpload returns the kept response of the idempotency key with a newly signed url, or nil when the key
+// is not used yet, the url of a file replaced since then does not open the newer file
+func (s *Service) replayUpload(ctx context.Context, userId string, key string, fingerprint string) (*proto.UploadResponse, error) {
+	result := &dto.IdempotentUpload{}
+	err := s.cacheRepo.GetCache(ctx, key, result)
+	if err == redis.Nil {
+		return nil, nil
+	}
+
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "upload image").
+			Msg("Error while connecting to redis server")
+		return nil, apperror.Unavailable(apperror.Cache, err)
+	}
+
+	if result.Fingerprint != fingerprint {
+		return nil, apperror.InvalidArgument("idempotencyKey", "Idempotency key is already used by another upload")
+	}
+
+	cacheFile, _, err := s.signUrl(ctx, &model.File{
+		Filename: result.Filename,
+		OwnerID:  userId,
+		Tag:      result.Tag,
+		Public:   result.Public,
+		Version:  result.Version,
+	})
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "upload image").
+			Str("filename", result.Filename).
+			Str("user_id", userId).
+			Msg("Error while trying to get signed url")
+		return nil, apperror.Unavailable(apperror.Storage, err)
+	}
+	cacheFile.ID = result.ID
+
+	url, err := s.fileUrl(ctx, userId, cacheFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proto.UploadResponse{Url: url, Id: result.ID, Version: result.Version}, nil
+}
+
+// idempotencyKey reads the key from the request or else from the metadata, an empty key means no idempotency
+func idempotencyKey(ctx context.Context, req *proto.UploadRequest) (string, error) {
+	key := req.IdempotencyKey
+	if key == "" {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if values := md.Get(IdempotencyKeyHeader); len(values) > 0 {
+			key = values[0]
+		}
+	}
+
+	if key == "" {
+		return "", nil
+	}
+
+	if len(key) > maxIdempotencyKeyLength {
+		return "", apperror.TooLarge("idempotencyKey", len(key), maxIdempotencyKeyLength)
+	}
+
+	for _, c := range key {
+		if c < 0x21 || c > 0x7e {
+			return "", apperror.InvalidArgument("idempotencyKey", "Idempotency key must be printable ascii")
+		}
+	}
+
+	return key, nil
+}
+
+func uploadFingerprint(req *proto.UploadRequest) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%d\x00%d\x00%t\x00%d\x00", req.Filename, req.Tag, req.Type, req.Public, req.IfMatch)
+	h.Write(req.Data)
+
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,157 @@
+package gcs
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
+	"github.com/isd-sgcu/rnkm65-file/src/app/utils"
+	cMock "github.com/isd-sgcu/rnkm65-file/src/mocks/cache"
+	fMock "github.com/isd-sgcu/rnkm65-file/src/mocks/file"
+	mock "github.com/isd-sgcu/rnkm65-file/src/mocks/gcs"
+	lMock "github.com/isd-sgcu/rnkm65-file/src/mocks/link"
+	sMock "github.com/isd-sgcu/rnkm65-file/src/mocks/share"
+	"github.com/isd-sgcu/rnkm65-file/src/proto"
+	"github.com/stretchr/testify/assert"
+	tMock "github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func (t *GCSServiceTest) idempotencyKeys(key string) (string, string) {
+	keys := utils.NewCacheKey("", 0)
+	keyHash := utils.Hash([]byte(key))
+
+	return keys.Idempotency(t.f.OwnerID, keyHash), keys.IdempotencyLock(t.f.OwnerID, keyHash)
+}
+
+func (t *GCSServiceTest) uploadRequest(key string) *proto.UploadRequest {
+	return &proto.UploadRequest{
+		Filename:       t.filename,
+		Data:           t.file,
+		UserId:         t.f.OwnerID,
+		Tag:            1,
+		IdempotencyKey: key,
+		Type:           proto.FileType_FILE_TYPE_IMAGE,
+	}
+}
+
+func (t *GCSServiceTest) TestUploadIdempotencyKeySavesResult() {
+	t.cacheConf.IdempotencyTTL = 900
+	resultKey, lockKey := t.idempotencyKeys("retry-1")
+
+	c := mock.ClientMock{}
+	c.On("Upload", t.file).Return(nil)
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return(t.url, nil)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("CreateOrUpdate", t.f.OwnerID, int64(0)).Return(t.f, nil, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", resultKey, &dto.IdempotentUpload{}).Return(nil, redis.Nil)
+	cacheRepo.On("AcquireLock", lockKey, defaultIdempotencyLockTTL).Return("token", nil)
+	cacheRepo.On("ReleaseLock", lockKey, "token").Return(nil)
+	cacheRepo.On("SaveCache", t.key, t.cacheFile.Url, t.ttl).Return(nil)
+	cacheRepo.On("SaveCache", resultKey, tMock.Anything, 900).Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.Upload(context.Background(), t.uploadRequest("retry-1"))
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), &proto.UploadResponse{Url: t.url, Id: t.f.ID.String()}, actual)
+	assert.Equal(t.T(), &dto.IdempotentUpload{
+		Fingerprint: uploadFingerprint(t.uploadRequest("retry-1")),
+		ID:          t.f.ID.String(),
+		Filename:    t.filename,
+		Tag:         1,
+	}, cacheRepo.V[resultKey])
+	cacheRepo.AssertCalled(t.T(), "ReleaseLock", lockKey, "token")
+}
+
+func (t *GCSServiceTest) TestUploadIdempotencyKeyReplay() {
+	t.cacheConf.IdempotencyTTL = 900
+	resultKey, _ := t.idempotencyKeys("retry-1")
+
+	c := mock.ClientMock{}
+	c.On("GetSignedUrl", defaultUrlExpiresIn).Return(t.url, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", resultKey, &dto.IdempotentUpload{}).Return(&dto.IdempotentUpload{
+		Fingerprint: uploadFingerprint(t.uploadRequest("")),
+		ID:          t.f.ID.String(),
+		Filename:    t.filename,
+		Tag:         1,
+	}, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(IdempotencyKeyHeader, "retry-1"))
+	actual, err := srv.Upload(ctx, t.uploadRequest(""))
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), &proto.UploadResponse{Url: t.url, Id: t.f.ID.String()}, actual)
+	c.AssertNotCalled(t.T(), "Upload", tMock.Anything)
+	c.AssertCalled(t.T(), "GetSignedUrl", defaultUrlExpiresIn)
+	cacheRepo.AssertNotCalled(t.T(), "AcquireLock", tMock.Anything, tMock.Anything)
+}
+
+func (t *GCSServiceTest) TestUploadIdempotencyKeyReusedForAnotherUpload() {
+	t.cacheConf.IdempotencyTTL = 900
+	resultKey, _ := t.idempotencyKeys("retry-1")
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", resultKey, &dto.IdempotentUpload{}).Return(&dto.IdempotentUpload{
+		Fingerprint: "another upload",
+		ID:          t.f.ID.String(),
+		Filename:    t.filename,
+	}, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.Upload(context.Background(), t.uploadRequest("retry-1"))
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.InvalidArgument, st.Code())
+}
+
+func (t *GCSServiceTest) TestUploadIdempotencyKeyInProgress() {
+	t.cacheConf.IdempotencyTTL = 900
+	t.cacheConf.IdempotencyLockTTL = 30
+	resultKey, lockKey := t.idempotencyKeys("retry-1")
+
+	c := mock.ClientMock{}
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", resultKey, &dto.IdempotentUpload{}).Return(nil, redis.Nil)
+	cacheRepo.On("AcquireLock", lockKey, 30).Return("", nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	actual, err := srv.Upload(context.Background(), t.uploadRequest("retry-1"))
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.Aborted, st.Code())
+	c.AssertNotCalled(t.T(), "Upload", tMock.Anything)
+	cacheRepo.AssertNotCalled(t.T(), "ReleaseLock", tMock.Anything, tMock.Anything)
+}
+
+func (t *GCSServiceTest) TestUploadIdempotencyKeyInvalid() {
+	t.cacheConf.IdempotencyTTL = 900
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	actual, err := srv.Upload(context.Background(), t.uploadRequest("retry 1"))
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.InvalidArgument, st.Code())
+}
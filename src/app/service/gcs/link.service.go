@@ -0,0 +1,245 @@
+package gcs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"github.com/isd-sgcu/rnkm65-file/src/app/apperror"
+	"github.com/isd-sgcu/rnkm65-file/src/app/auth"
+	model "github.com/isd-sgcu/rnkm65-file/src/app/model/file"
+	linkModel "github.com/isd-sgcu/rnkm65-file/src/app/model/link"
+	"github.com/isd-sgcu/rnkm65-file/src/app/utils"
+	"github.com/isd-sgcu/rnkm65-file/src/constant/audit"
+	"github.com/isd-sgcu/rnkm65-file/src/proto"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	defaultLinkTTL       = 7 * 24 * time.Hour
+	defaultLinkExpiresIn = 5 * time.Minute
+	linkTokenSize        = 32
+)
+
+func (s *Service) CreateShareLink(ctx context.Context, req *proto.CreateShareLinkRequest) (res *proto.CreateShareLinkResponse, err error) {
+	var userId string
+	defer func() { s.record(ctx, audit.CREATE_SHARE_LINK, userId, req.FileId, err) }()
+
+	if req.MaxDownloads < 0 {
+		return nil, apperror.InvalidArgument("maxDownloads", "Max downloads cannot be negative")
+	}
+
+	expiresAt := time.Now().Add(s.linkTTL())
+	if req.ExpiresAt != 0 {
+		expiresAt = time.Unix(req.ExpiresAt, 0)
+		if !expiresAt.After(time.Now()) {
+			return nil, apperror.InvalidArgument("expiresAt", "Expiry must be in the future")
+		}
+	}
+
+	f := &model.File{}
+	err = s.repository.FindByID(ctx, req.FileId, f)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFound("file", req.FileId)
+		}
+
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "create share link").
+			Str("file_id", req.FileId).
+			Msg("Error while trying to query data")
+		return nil, apperror.Unavailable(apperror.Database, err)
+	}
+	userId = f.OwnerID
+
+	// a file of someone else is not found like a missing one so the callers cannot probe the file ids
+	if err := s.authorize(ctx, "create share link", auth.Write, f.OwnerID); err != nil {
+		if errors.Is(err, apperror.ErrPermission) {
+			return nil, apperror.NotFound("file", req.FileId)
+		}
+		return nil, err
+	}
+
+	token, err := newLinkToken()
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "create share link").
+			Str("file_id", req.FileId).
+			Msg("Cannot generate the link token")
+		return nil, apperror.Internal(err)
+	}
+
+	l := &linkModel.Link{
+		FileID:       f.ID,
+		OwnerID:      f.OwnerID,
+		TokenHash:    utils.Hash([]byte(token)),
+		ExpiresAt:    expiresAt,
+		MaxDownloads: int(req.MaxDownloads),
+	}
+
+	if caller, ok := auth.FromContext(ctx); ok {
+		l.CreatedBy = caller.Subject
+		if l.CreatedBy == "" {
+			l.CreatedBy = caller.Service
+		}
+	}
+
+	err = s.linkRepo.Create(l)
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "create share link").
+			Str("file_id", req.FileId).
+			Msg("Error while saving link data")
+		return nil, apperror.Unavailable(apperror.Database, err)
+	}
+
+	return &proto.CreateShareLinkResponse{
+		Id:        l.ID.String(),
+		Token:     token,
+		Url:       s.linkConf.BaseUrl + token,
+		ExpiresAt: l.ExpiresAt.Unix(),
+	}, nil
+}
+
+func (s *Service) RevokeShareLink(ctx context.Context, req *proto.RevokeShareLinkRequest) (res *proto.RevokeShareLinkResponse, err error) {
+	var userId, fileId string
+	defer func() { s.record(ctx, audit.REVOKE_SHARE_LINK, userId, fileId, err) }()
+
+	l := &linkModel.Link{}
+	err = s.linkRepo.FindByID(req.Id, l)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFound("link", req.Id)
+		}
+
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "revoke share link").
+			Str("link_id", req.Id).
+			Msg("Error while trying to query data")
+		return nil, apperror.Unavailable(apperror.Database, err)
+	}
+	userId, fileId = l.OwnerID, l.FileID.String()
+
+	if err := s.authorize(ctx, "revoke share link", auth.Write, l.OwnerID); err != nil {
+		return nil, err
+	}
+
+	err = s.linkRepo.Delete(req.Id)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "revoke share link").
+			Str("link_id", req.Id).
+			Msg("Error while deleting link data")
+		return nil, apperror.Unavailable(apperror.Database, err)
+	}
+
+	return &proto.RevokeShareLinkResponse{}, nil
+}
+
+// ResolveShareLink checks the token and counts the download, it returns a freshly signed url of the file
+func (s *Service) ResolveShareLink(ctx context.Context, token string) (url string, err error) {
+	var userId, fileId string
+	defer func() { s.record(ctx, audit.RESOLVE_SHARE_LINK, userId, fileId, err) }()
+
+	l := &linkModel.Link{}
+	err = s.linkRepo.FindByTokenHash(utils.Hash([]byte(token)), l)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", apperror.NotFound("link", "")
+		}
+
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "resolve share link").
+			Msg("Error while trying to query data")
+		return "", apperror.Unavailable(apperror.Database, err)
+	}
+
+	userId, fileId = l.OwnerID, l.FileID.String()
+
+	if !l.ExpiresAt.After(time.Now()) {
+		return "", apperror.FailedPrecondition("EXPIRED", "link:"+l.ID.String(), "Link expired")
+	}
+
+	f := &model.File{}
+	err = s.repository.FindByID(ctx, l.FileID.String(), f)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", apperror.NotFound("file", l.FileID.String())
+		}
+
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "resolve share link").
+			Str("link_id", l.ID.String()).
+			Msg("Error while trying to query data")
+		return "", apperror.Unavailable(apperror.Database, err)
+	}
+
+	if f.Public {
+		url = s.client.GetPublicUrl(f.Filename)
+	} else {
+		url, err = s.client.GetSignedUrl(ctx, f.Filename, s.linkUrlExpiresIn())
+		if err != nil {
+			log.Ctx(ctx).Error().
+				Err(err).
+				Str("module", "resolve share link").
+				Str("link_id", l.ID.String()).
+				Str("filename", f.Filename).
+				Msg("Cannot connect to google cloud storage")
+			return "", apperror.Unavailable(apperror.Storage, err)
+		}
+	}
+
+	ttl := int(time.Until(l.ExpiresAt)/time.Second) + 1
+	downloads, err := s.cacheRepo.Increment(ctx, s.keys.Downloads(l.ID.String()), ttl)
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "resolve share link").
+			Str("link_id", l.ID.String()).
+			Msg("Error while connecting to redis server")
+
+		if l.MaxDownloads > 0 {
+			return "", apperror.Unavailable(apperror.Cache, err)
+		}
+	}
+
+	if l.MaxDownloads > 0 && downloads > int64(l.MaxDownloads) {
+		return "", apperror.QuotaExceeded("link:"+l.ID.String(), "Download limit reached")
+	}
+
+	return url, nil
+}
+
+func (s *Service) linkTTL() time.Duration {
+	if s.linkConf.DefaultTTL > 0 {
+		return time.Duration(s.linkConf.DefaultTTL) * time.Second
+	}
+
+	return defaultLinkTTL
+}
+
+func (s *Service) linkUrlExpiresIn() time.Duration {
+	if s.linkConf.UrlExpiry > 0 {
+		return time.Duration(s.linkConf.UrlExpiry) * time.Second
+	}
+
+	return defaultLinkExpiresIn
+}
+
+func newLinkToken() (string, error) {
+	b := make([]byte, linkTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,233 @@
+package gcs
+
+import (
+	"context"
+	"github.com/bxcodec/faker/v3"
+	"github.com/google/uuid"
+	"github.com/isd-sgcu/rnkm65-file/src/app/apperror"
+	"github.com/isd-sgcu/rnkm65-file/src/app/auth"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/link"
+	"github.com/isd-sgcu/rnkm65-file/src/app/utils"
+	cMock "github.com/isd-sgcu/rnkm65-file/src/mocks/cache"
+	fMock "github.com/isd-sgcu/rnkm65-file/src/mocks/file"
+	mock "github.com/isd-sgcu/rnkm65-file/src/mocks/gcs"
+	lMock "github.com/isd-sgcu/rnkm65-file/src/mocks/link"
+	sMock "github.com/isd-sgcu/rnkm65-file/src/mocks/share"
+	"github.com/isd-sgcu/rnkm65-file/src/proto"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	tMock "github.com/stretchr/testify/mock"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+func (t *GCSServiceTest) newLink(maxDownloads int) *link.Link {
+	return &link.Link{
+		Base:         model.Base{ID: uuid.New()},
+		FileID:       t.f.ID,
+		OwnerID:      t.f.OwnerID,
+		ExpiresAt:    time.Now().Add(time.Hour).Truncate(time.Second),
+		MaxDownloads: maxDownloads,
+	}
+}
+
+func (t *GCSServiceTest) TestCreateShareLinkSuccess() {
+	l := t.newLink(3)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByID", t.f.ID.String()).Return(t.f, nil)
+
+	linkRepo := lMock.RepositoryMock{}
+	linkRepo.On("Create", t.f.ID, 3).Return(l, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &repo, &sMock.RepositoryMock{}, &linkRepo, &cMock.RepositoryMock{}, nil)
+
+	actual, err := srv.CreateShareLink(context.Background(), &proto.CreateShareLinkRequest{
+		FileId:       t.f.ID.String(),
+		ExpiresAt:    l.ExpiresAt.Unix(),
+		MaxDownloads: 3,
+	})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), l.ID.String(), actual.Id)
+	assert.Equal(t.T(), l.ExpiresAt.Unix(), actual.ExpiresAt)
+	assert.Len(t.T(), actual.Token, 43)
+	assert.Equal(t.T(), t.linkConf.BaseUrl+actual.Token, actual.Url)
+}
+
+func (t *GCSServiceTest) TestCreateShareLinkInvalidArgument() {
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	for _, req := range []*proto.CreateShareLinkRequest{
+		{FileId: t.f.ID.String(), MaxDownloads: -1},
+		{FileId: t.f.ID.String(), ExpiresAt: time.Now().Add(-time.Minute).Unix()},
+	} {
+		actual, err := srv.CreateShareLink(context.Background(), req)
+
+		st, ok := status.FromError(err)
+
+		assert.True(t.T(), ok)
+		assert.Nil(t.T(), actual)
+		assert.Equal(t.T(), codes.InvalidArgument, st.Code())
+	}
+}
+
+func (t *GCSServiceTest) TestCreateShareLinkNotOwner() {
+	t.authConf.Enabled = true
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByID", t.f.ID.String()).Return(t.f, nil)
+
+	linkRepo := lMock.RepositoryMock{}
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &repo, &sMock.RepositoryMock{}, &linkRepo, &cMock.RepositoryMock{}, nil)
+
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "web", Subject: faker.UUIDDigit()})
+	actual, err := srv.CreateShareLink(ctx, &proto.CreateShareLinkRequest{FileId: t.f.ID.String()})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.NotFound, st.Code())
+	assert.Equal(t.T(), "Not found file", st.Message())
+	linkRepo.AssertNotCalled(t.T(), "Create")
+}
+
+func (t *GCSServiceTest) TestRevokeShareLinkSuccess() {
+	l := t.newLink(0)
+
+	linkRepo := lMock.RepositoryMock{}
+	linkRepo.On("FindByID", l.ID.String()).Return(l, nil)
+	linkRepo.On("Delete", l.ID.String()).Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &linkRepo, &cMock.RepositoryMock{}, nil)
+
+	actual, err := srv.RevokeShareLink(context.Background(), &proto.RevokeShareLinkRequest{Id: l.ID.String()})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), &proto.RevokeShareLinkResponse{}, actual)
+	linkRepo.AssertExpectations(t.T())
+}
+
+func (t *GCSServiceTest) TestResolveShareLinkSuccess() {
+	token := "token"
+	l := t.newLink(3)
+
+	c := mock.ClientMock{}
+	c.On("GetSignedUrl", defaultLinkExpiresIn).Return(t.url, nil)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByID", t.f.ID.String()).Return(t.f, nil)
+
+	linkRepo := lMock.RepositoryMock{}
+	linkRepo.On("FindByTokenHash", utils.Hash([]byte(token))).Return(l, nil)
+
+	cacheRepo := cMock.RepositoryMock{}
+	cacheRepo.On("Increment", utils.NewCacheKey("", 0).Downloads(l.ID.String()), tMock.Anything).Return(int64(3), nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &linkRepo, &cacheRepo, nil)
+
+	actual, err := srv.ResolveShareLink(context.Background(), token)
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), t.url, actual)
+}
+
+func (t *GCSServiceTest) TestResolveShareLinkLimitReached() {
+	l := t.newLink(3)
+
+	c := mock.ClientMock{}
+	c.On("GetSignedUrl", defaultLinkExpiresIn).Return(t.url, nil)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByID", t.f.ID.String()).Return(t.f, nil)
+
+	linkRepo := lMock.RepositoryMock{}
+	linkRepo.On("FindByTokenHash", tMock.Anything).Return(l, nil)
+
+	cacheRepo := cMock.RepositoryMock{}
+	cacheRepo.On("Increment", tMock.Anything, tMock.Anything).Return(int64(4), nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &linkRepo, &cacheRepo, nil)
+
+	actual, err := srv.ResolveShareLink(context.Background(), "token")
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), "", actual)
+	assert.Equal(t.T(), codes.ResourceExhausted, st.Code())
+}
+
+func (t *GCSServiceTest) TestResolveShareLinkExpired() {
+	l := t.newLink(0)
+	l.ExpiresAt = time.Now().Add(-time.Second)
+
+	linkRepo := lMock.RepositoryMock{}
+	linkRepo.On("FindByTokenHash", tMock.Anything).Return(l, nil)
+
+	cacheRepo := cMock.RepositoryMock{}
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &linkRepo, &cacheRepo, nil)
+
+	_, err := srv.ResolveShareLink(context.Background(), "token")
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), codes.FailedPrecondition, st.Code())
+	assert.ErrorIs(t.T(), err, apperror.ErrPrecondition)
+	cacheRepo.AssertNotCalled(t.T(), "Increment")
+}
+
+func (t *GCSServiceTest) TestResolveShareLinkRevoked() {
+	linkRepo := lMock.RepositoryMock{}
+	linkRepo.On("FindByTokenHash", tMock.Anything).Return(nil, gorm.ErrRecordNotFound)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &linkRepo, &cMock.RepositoryMock{}, nil)
+
+	_, err := srv.ResolveShareLink(context.Background(), "token")
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), codes.NotFound, st.Code())
+}
+
+func (t *GCSServiceTest) TestResolveShareLinkRedisDown() {
+	redisErr := errors.New("Cannot connect to redis server")
+
+	c := mock.ClientMock{}
+	c.On("GetSignedUrl", defaultLinkExpiresIn).Return(t.url, nil)
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByID", t.f.ID.String()).Return(t.f, nil)
+
+	cacheRepo := cMock.RepositoryMock{}
+	cacheRepo.On("Increment", tMock.Anything, tMock.Anything).Return(int64(0), redisErr)
+
+	for _, maxDownloads := range []int{0, 3} {
+		linkRepo := lMock.RepositoryMock{}
+		linkRepo.On("FindByTokenHash", tMock.Anything).Return(t.newLink(maxDownloads), nil)
+
+		srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &c, &repo, &sMock.RepositoryMock{}, &linkRepo, &cacheRepo, nil)
+
+		actual, err := srv.ResolveShareLink(context.Background(), "token")
+
+		if maxDownloads == 0 {
+			assert.Nil(t.T(), err)
+			assert.True(t.T(), strings.HasPrefix(actual, t.url))
+			continue
+		}
+
+		st, ok := status.FromError(err)
+
+		assert.True(t.T(), ok)
+		assert.Equal(t.T(), codes.Unavailable, st.Code())
+	}
+}
@@ -0,0 +1,187 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"github.com/isd-sgcu/rnkm65-file/src/app/apperror"
+	"github.com/isd-sgcu/rnkm65-file/src/app/auth"
+	model "github.com/isd-sgcu/rnkm65-file/src/app/model/file"
+	shareModel "github.com/isd-sgcu/rnkm65-file/src/app/model/share"
+	"github.com/isd-sgcu/rnkm65-file/src/constant/audit"
+	"github.com/isd-sgcu/rnkm65-file/src/constant/share"
+	"github.com/isd-sgcu/rnkm65-file/src/proto"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"time"
+)
+
+func (s *Service) ShareFile(ctx context.Context, req *proto.ShareFileRequest) (res *proto.ShareFileResponse, err error) {
+	userId := s.userId(ctx, req.UserId)
+	var fileId string
+	defer func() { s.record(ctx, audit.SHARE_FILE, userId, fileId, err) }()
+
+	if err := s.authorize(ctx, "share file", auth.Write, userId); err != nil {
+		return nil, err
+	}
+
+	granteeType, granteeId, err := grantee(req.GranteeUserId, req.GranteeGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	if granteeType == share.USER && granteeId == userId {
+		return nil, apperror.InvalidArgument("granteeUserId", "Cannot share the file with its owner")
+	}
+
+	sh := &shareModel.Share{
+		OwnerID:     userId,
+		GranteeType: string(granteeType),
+		GranteeID:   granteeId,
+	}
+
+	if req.ExpiresAt != 0 {
+		expiresAt := time.Unix(req.ExpiresAt, 0)
+		if !expiresAt.After(time.Now()) {
+			return nil, apperror.InvalidArgument("expiresAt", "Expiry must be in the future")
+		}
+		sh.ExpiresAt = &expiresAt
+	}
+
+	if caller, ok := auth.FromContext(ctx); ok {
+		sh.CreatedBy = caller.Subject
+		if sh.CreatedBy == "" {
+			sh.CreatedBy = caller.Service
+		}
+	}
+
+	f := &model.File{}
+	err = s.repository.FindByOwnerID(ctx, userId, f)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFound("file", userId)
+		}
+
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "share file").
+			Str("user_id", userId).
+			Msg("Error while trying to query data")
+		return nil, apperror.Unavailable(apperror.Database, err)
+	}
+	fileId = f.ID.String()
+
+	err = s.shareRepo.CreateOrUpdate(sh)
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "share file").
+			Str("user_id", userId).
+			Str("grantee_type", sh.GranteeType).
+			Str("grantee_id", sh.GranteeID).
+			Msg("Error while saving share data")
+		return nil, apperror.Unavailable(apperror.Database, err)
+	}
+
+	return &proto.ShareFileResponse{Share: rawToShare(sh)}, nil
+}
+
+func (s *Service) RevokeShare(ctx context.Context, req *proto.RevokeShareRequest) (res *proto.RevokeShareResponse, err error) {
+	userId := s.userId(ctx, req.UserId)
+	defer func() { s.record(ctx, audit.REVOKE_SHARE, userId, "", err) }()
+
+	if err := s.authorize(ctx, "revoke share", auth.Write, userId); err != nil {
+		return nil, err
+	}
+
+	granteeType, granteeId, err := grantee(req.GranteeUserId, req.GranteeGroup)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.shareRepo.Delete(userId, string(granteeType), granteeId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFound("share", granteeId)
+		}
+
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "revoke share").
+			Str("user_id", userId).
+			Str("grantee_type", string(granteeType)).
+			Str("grantee_id", granteeId).
+			Msg("Error while deleting share data")
+		return nil, apperror.Unavailable(apperror.Database, err)
+	}
+
+	return &proto.RevokeShareResponse{}, nil
+}
+
+// ListSharedWithMe lists the unexpired grants to the user, the groups are only known for the caller itself
+func (s *Service) ListSharedWithMe(ctx context.Context, req *proto.ListSharedWithMeRequest) (*proto.ListSharedWithMeResponse, error) {
+	userId := s.userId(ctx, req.UserId)
+	if err := s.authorize(ctx, "list shared with me", auth.Read, userId); err != nil {
+		return nil, err
+	}
+
+	if userId == "" {
+		return nil, apperror.InvalidArgument("userId", "User id is required")
+	}
+
+	var groups []string
+	if caller, ok := auth.FromContext(ctx); ok && caller.IsOwner(userId) {
+		groups = caller.Groups
+	}
+
+	var shares []shareModel.Share
+	err := s.shareRepo.FindSharedWith(userId, groups, &shares)
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "list shared with me").
+			Str("user_id", userId).
+			Msg("Error while trying to query data")
+		return nil, apperror.Unavailable(apperror.Database, err)
+	}
+
+	result := make([]*proto.Share, 0, len(shares))
+	for i := range shares {
+		result = append(result, rawToShare(&shares[i]))
+	}
+
+	return &proto.ListSharedWithMeResponse{Shares: result}, nil
+}
+
+func grantee(userId string, group string) (share.GranteeType, string, error) {
+	switch {
+	case userId != "" && group != "":
+		return "", "", apperror.InvalidArgument("granteeGroup", "Grant either a user or a group, not both")
+	case userId != "":
+		return share.USER, userId, nil
+	case group != "":
+		return share.GROUP, group, nil
+	default:
+		return "", "", apperror.InvalidArgument("granteeUserId", "Grantee user id or group is required")
+	}
+}
+
+func rawToShare(in *shareModel.Share) *proto.Share {
+	result := &proto.Share{
+		Id:        in.ID.String(),
+		UserId:    in.OwnerID,
+		CreatedAt: in.CreatedAt.Unix(),
+	}
+
+	switch share.GranteeType(in.GranteeType) {
+	case share.USER:
+		result.GranteeUserId = in.GranteeID
+	case share.GROUP:
+		result.GranteeGroup = in.GranteeID
+	}
+
+	if in.ExpiresAt != nil {
+		result.ExpiresAt = in.ExpiresAt.Unix()
+	}
+
+	return result
+}
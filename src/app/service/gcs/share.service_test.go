@@ -0,0 +1,170 @@
+package gcs
+
+import (
+	"context"
+	"github.com/bxcodec/faker/v3"
+	"github.com/google/uuid"
+	"github.com/isd-sgcu/rnkm65-file/src/app/auth"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/file"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/share"
+	cMock "github.com/isd-sgcu/rnkm65-file/src/mocks/cache"
+	fMock "github.com/isd-sgcu/rnkm65-file/src/mocks/file"
+	mock "github.com/isd-sgcu/rnkm65-file/src/mocks/gcs"
+	lMock "github.com/isd-sgcu/rnkm65-file/src/mocks/link"
+	sMock "github.com/isd-sgcu/rnkm65-file/src/mocks/share"
+	"github.com/isd-sgcu/rnkm65-file/src/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"time"
+)
+
+func (t *GCSServiceTest) TestShareFileSuccess() {
+	t.authConf.Enabled = true
+
+	grantee := faker.UUIDDigit()
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	sh := &share.Share{
+		Base:        model.Base{ID: uuid.New(), CreatedAt: time.Now()},
+		OwnerID:     t.f.OwnerID,
+		GranteeType: "user",
+		GranteeID:   grantee,
+		ExpiresAt:   &expiresAt,
+	}
+
+	want := &proto.ShareFileResponse{Share: &proto.Share{
+		Id:            sh.ID.String(),
+		UserId:        t.f.OwnerID,
+		GranteeUserId: grantee,
+		ExpiresAt:     expiresAt.Unix(),
+		CreatedAt:     sh.CreatedAt.Unix(),
+	}}
+
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(t.f, nil)
+
+	shareRepo := sMock.RepositoryMock{}
+	shareRepo.On("CreateOrUpdate", t.f.OwnerID, "user", grantee).Return(sh, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &repo, &shareRepo, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "web", Subject: t.f.OwnerID})
+	actual, err := srv.ShareFile(ctx, &proto.ShareFileRequest{
+		GranteeUserId: grantee,
+		ExpiresAt:     expiresAt.Unix(),
+	})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+}
+
+func (t *GCSServiceTest) TestShareFileInvalidGrantee() {
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &sMock.RepositoryMock{}, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	for _, req := range []*proto.ShareFileRequest{
+		{UserId: t.f.OwnerID},
+		{UserId: t.f.OwnerID, GranteeUserId: faker.UUIDDigit(), GranteeGroup: "staff"},
+		{UserId: t.f.OwnerID, GranteeUserId: t.f.OwnerID},
+		{UserId: t.f.OwnerID, GranteeGroup: "staff", ExpiresAt: time.Now().Add(-time.Minute).Unix()},
+	} {
+		actual, err := srv.ShareFile(context.Background(), req)
+
+		st, ok := status.FromError(err)
+
+		assert.True(t.T(), ok)
+		assert.Nil(t.T(), actual)
+		assert.Equal(t.T(), codes.InvalidArgument, st.Code())
+	}
+}
+
+func (t *GCSServiceTest) TestShareFileNotFound() {
+	repo := fMock.RepositoryMock{}
+	repo.On("FindByOwnerID", t.f.OwnerID, &file.File{}).Return(nil, gorm.ErrRecordNotFound)
+
+	shareRepo := sMock.RepositoryMock{}
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &repo, &shareRepo, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	actual, err := srv.ShareFile(context.Background(), &proto.ShareFileRequest{UserId: t.f.OwnerID, GranteeGroup: "staff"})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.NotFound, st.Code())
+	shareRepo.AssertNotCalled(t.T(), "CreateOrUpdate")
+}
+
+func (t *GCSServiceTest) TestRevokeShareSuccess() {
+	shareRepo := sMock.RepositoryMock{}
+	shareRepo.On("Delete", t.f.OwnerID, "group", "staff").Return(nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &shareRepo, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	actual, err := srv.RevokeShare(context.Background(), &proto.RevokeShareRequest{UserId: t.f.OwnerID, GranteeGroup: "staff"})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), &proto.RevokeShareResponse{}, actual)
+}
+
+func (t *GCSServiceTest) TestRevokeShareNotFound() {
+	shareRepo := sMock.RepositoryMock{}
+	shareRepo.On("Delete", t.f.OwnerID, "user", "someone").Return(gorm.ErrRecordNotFound)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &shareRepo, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	actual, err := srv.RevokeShare(context.Background(), &proto.RevokeShareRequest{UserId: t.f.OwnerID, GranteeUserId: "someone"})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.NotFound, st.Code())
+}
+
+func (t *GCSServiceTest) TestListSharedWithMeSuccess() {
+	t.authConf.Enabled = true
+
+	subject := faker.UUIDDigit()
+	shares := []share.Share{
+		{Base: model.Base{ID: uuid.New()}, OwnerID: t.f.OwnerID, GranteeType: "group", GranteeID: "staff"},
+	}
+
+	want := &proto.ListSharedWithMeResponse{Shares: []*proto.Share{
+		{Id: shares[0].ID.String(), UserId: t.f.OwnerID, GranteeGroup: "staff", CreatedAt: shares[0].CreatedAt.Unix()},
+	}}
+
+	shareRepo := sMock.RepositoryMock{}
+	shareRepo.On("FindSharedWith", subject, []string{"staff"}).Return(shares, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &shareRepo, &lMock.RepositoryMock{}, &cMock.RepositoryMock{}, nil)
+
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "web", Subject: subject, Groups: []string{"staff"}})
+	actual, err := srv.ListSharedWithMe(ctx, &proto.ListSharedWithMeRequest{})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), want, actual)
+}
+
+func (t *GCSServiceTest) TestGetSignedUrlSharedWithGroup() {
+	t.authConf.Enabled = true
+
+	subject := faker.UUIDDigit()
+
+	shareRepo := sMock.RepositoryMock{}
+	shareRepo.On("FindGranted", []string{t.f.OwnerID}, subject, []string{"staff"}).Return([]share.Share{{OwnerID: t.f.OwnerID}}, nil)
+
+	cacheRepo := cMock.RepositoryMock{V: map[string]interface{}{}}
+	cacheRepo.On("GetCache", t.key, &dto.CacheFile{}).Return(t.cacheFile, nil)
+
+	srv := NewService(t.conf, t.ttl, t.cacheConf, t.authConf, t.linkConf, &mock.ClientMock{}, &fMock.RepositoryMock{}, &shareRepo, &lMock.RepositoryMock{}, &cacheRepo, nil)
+
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "web", Subject: subject, Groups: []string{"staff"}})
+	actual, err := srv.GetSignedUrl(ctx, &proto.GetSignedUrlRequest{UserId: t.f.OwnerID})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), &proto.GetSignedUrlResponse{Url: t.url}, actual)
+}
@@ -0,0 +1,229 @@
+package health
+
+import (
+	"context"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/health"
+	"github.com/isd-sgcu/rnkm65-file/src/config"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"sync"
+	"time"
+)
+
+const (
+	defaultInterval = 10 * time.Second
+	defaultTimeout  = 3 * time.Second
+
+	StatusOk          = "ok"
+	StatusUnavailable = "unavailable"
+	StatusUnknown     = "unknown"
+	StatusStopping    = "stopping"
+)
+
+// Check probes one dependency, it has to return before the context is done
+type Check func(context.Context) error
+
+// Service probes the dependencies in the background and sets the serving status of each grpc service
+// from the dependencies it needs, the overall "" status is serving only when every dependency is up
+type Service struct {
+	conf     config.Health
+	server   IServer
+	checks   map[string]Check
+	services map[string][]string
+	now      func() time.Time
+
+	mu      sync.RWMutex
+	results map[string]*dto.Check
+	stopped bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+type IServer interface {
+	SetServingStatus(string, grpc_health_v1.HealthCheckResponse_ServingStatus)
+	Shutdown()
+}
+
+func NewService(conf config.Health, server IServer) *Service {
+	return &Service{
+		conf:     conf,
+		server:   server,
+		checks:   map[string]Check{},
+		services: map[string][]string{},
+		now:      time.Now,
+		results:  map[string]*dto.Check{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// AddCheck registers the probe of the dependency, it has to be called before Start
+func (s *Service) AddCheck(name string, check Check) {
+	s.checks[name] = check
+	s.results[name] = &dto.Check{Status: StatusUnknown}
+}
+
+// AddService sets which dependencies the grpc service needs to serve, it has to be called before Start
+func (s *Service) AddService(service string, dependencies ...string) {
+	s.services[service] = dependencies
+	s.server.SetServingStatus(service, grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+}
+
+// Start probes at once and then every interval until Stop is called, the services are not serving until the first probe passes
+func (s *Service) Start() {
+	s.server.SetServingStatus("", grpc_health_v1.HealthCheckResponse_NOT_SERVING)
+
+	go func() {
+		defer close(s.done)
+
+		s.CheckOnce()
+
+		ticker := time.NewTicker(s.interval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.CheckOnce()
+			}
+		}
+	}()
+}
+
+// Stop ends the probes and reports every service as not serving and the report as not ready so the load balancer
+// and the endpoints drain the traffic
+func (s *Service) Stop() {
+	close(s.stop)
+	<-s.done
+
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+
+	s.server.Shutdown()
+}
+
+// CheckOnce runs every probe concurrently and updates the statuses
+func (s *Service) CheckOnce() {
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for name, check := range s.checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			start := s.now()
+			err := check(ctx)
+			s.update(name, err, s.now().Sub(start))
+		}(name, check)
+	}
+	wg.Wait()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for service, dependencies := range s.services {
+		s.server.SetServingStatus(service, s.servingStatus(dependencies))
+	}
+	s.server.SetServingStatus("", s.servingStatus(s.names()))
+}
+
+// Report returns the last probe of each dependency, ok reports whether the service is ready, it is never ready once stopped
+func (s *Service) Report() (*dto.Report, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	report := &dto.Report{
+		Status: StatusOk,
+		Checks: make(map[string]*dto.Check, len(s.results)),
+	}
+
+	for name, result := range s.results {
+		check := *result
+		report.Checks[name] = &check
+
+		if check.Status != StatusOk {
+			report.Status = StatusUnavailable
+		}
+	}
+
+	if s.stopped {
+		report.Status = StatusStopping
+	}
+
+	return report, report.Status == StatusOk
+}
+
+func (s *Service) update(name string, err error, latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := s.results[name]
+	previous := result.Status
+
+	result.CheckedAt = s.now()
+	result.Latency = latency.String()
+
+	if err != nil {
+		result.Status = StatusUnavailable
+		result.Failures++
+
+		log.Error().
+			Err(err).
+			Str("module", "health").
+			Str("dependency", name).
+			Int("failures", result.Failures).
+			Msg("Dependency check failed")
+		return
+	}
+
+	result.Status = StatusOk
+	result.Failures = 0
+
+	if previous == StatusUnavailable {
+		log.Info().
+			Str("module", "health").
+			Str("dependency", name).
+			Msg("Dependency recovered")
+	}
+}
+
+func (s *Service) servingStatus(dependencies []string) grpc_health_v1.HealthCheckResponse_ServingStatus {
+	for _, name := range dependencies {
+		if result, ok := s.results[name]; !ok || result.Status != StatusOk {
+			return grpc_health_v1.HealthCheckResponse_NOT_SERVING
+		}
+	}
+
+	return grpc_health_v1.HealthCheckResponse_SERVING
+}
+
+func (s *Service) names() []string {
+	names := make([]string, 0, len(s.checks))
+	for name := range s.checks {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func (s *Service) interval() time.Duration {
+	if s.conf.Interval > 0 {
+		return time.Duration(s.conf.Interval) * time.Second
+	}
+
+	return defaultInterval
+}
+
+func (s *Service) timeout() time.Duration {
+	if s.conf.Timeout > 0 {
+		return time.Duration(s.conf.Timeout) * time.Second
+	}
+
+	return defaultTimeout
+}
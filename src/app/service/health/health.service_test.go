@@ -0,0 +1,111 @@
+package health
+
+import (
+	"context"
+	"errors"
+	healthHdr "github.com/isd-sgcu/rnkm65-file/src/app/handler/health"
+	"github.com/isd-sgcu/rnkm65-file/src/config"
+	hMock "github.com/isd-sgcu/rnkm65-file/src/mocks/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type HealthServiceTest struct {
+	suite.Suite
+	server   *hMock.ServerMock
+	redisErr error
+	srv      *Service
+}
+
+func TestHealthService(t *testing.T) {
+	suite.Run(t, new(HealthServiceTest))
+}
+
+func (t *HealthServiceTest) SetupTest() {
+	t.server = &hMock.ServerMock{}
+	t.redisErr = nil
+
+	t.srv = NewService(config.Health{}, t.server)
+	t.srv.AddCheck("database", func(context.Context) error { return nil })
+	t.srv.AddCheck("redis", func(context.Context) error { return t.redisErr })
+	t.srv.AddService("file.FileService", "database", "redis")
+	t.srv.AddService("file.WebhookService", "database")
+}
+
+func (t *HealthServiceTest) TestNotServingBeforeFirstCheck() {
+	report, ok := t.srv.Report()
+
+	assert.False(t.T(), ok)
+	assert.Equal(t.T(), StatusUnknown, report.Checks["database"].Status)
+	assert.Equal(t.T(), grpc_health_v1.HealthCheckResponse_NOT_SERVING, t.server.Statuses["file.FileService"])
+}
+
+func (t *HealthServiceTest) TestServing() {
+	t.srv.CheckOnce()
+
+	report, ok := t.srv.Report()
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), StatusOk, report.Status)
+	assert.Equal(t.T(), StatusOk, report.Checks["redis"].Status)
+	assert.Equal(t.T(), grpc_health_v1.HealthCheckResponse_SERVING, t.server.Statuses["file.FileService"])
+	assert.Equal(t.T(), grpc_health_v1.HealthCheckResponse_SERVING, t.server.Statuses[""])
+}
+
+func (t *HealthServiceTest) TestDependencyDown() {
+	t.redisErr = errors.New("dial tcp: connection refused")
+
+	t.srv.CheckOnce()
+	t.srv.CheckOnce()
+
+	report, ok := t.srv.Report()
+
+	assert.False(t.T(), ok)
+	assert.Equal(t.T(), StatusUnavailable, report.Status)
+	assert.Equal(t.T(), StatusUnavailable, report.Checks["redis"].Status)
+	assert.Equal(t.T(), 2, report.Checks["redis"].Failures)
+	assert.Equal(t.T(), StatusOk, report.Checks["database"].Status)
+	assert.Equal(t.T(), grpc_health_v1.HealthCheckResponse_NOT_SERVING, t.server.Statuses["file.FileService"])
+	assert.Equal(t.T(), grpc_health_v1.HealthCheckResponse_SERVING, t.server.Statuses["file.WebhookService"])
+	assert.Equal(t.T(), grpc_health_v1.HealthCheckResponse_NOT_SERVING, t.server.Statuses[""])
+}
+
+func (t *HealthServiceTest) TestDependencyRecovered() {
+	t.redisErr = errors.New("dial tcp: connection refused")
+	t.srv.CheckOnce()
+
+	t.redisErr = nil
+	t.srv.CheckOnce()
+
+	report, ok := t.srv.Report()
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), 0, report.Checks["redis"].Failures)
+	assert.Equal(t.T(), grpc_health_v1.HealthCheckResponse_SERVING, t.server.Statuses["file.FileService"])
+}
+
+func (t *HealthServiceTest) TestStopShutsDownServer() {
+	t.srv.Start()
+	t.srv.Stop()
+
+	assert.True(t.T(), t.server.IsShutdown)
+}
+
+func (t *HealthServiceTest) TestStopNotReady() {
+	t.srv.Start()
+	t.srv.Stop()
+
+	w := httptest.NewRecorder()
+	healthHdr.NewHandler(t.srv).Ready(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+	report, ok := t.srv.Report()
+
+	assert.False(t.T(), ok)
+	assert.Equal(t.T(), StatusStopping, report.Status)
+	assert.Equal(t.T(), StatusOk, report.Checks["database"].Status)
+	assert.Equal(t.T(), http.StatusServiceUnavailable, w.Code)
+}
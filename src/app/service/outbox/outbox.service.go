@@ -0,0 +1,182 @@
+package outbox
+
+import (
+	"context"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/event"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/outbox"
+	"github.com/isd-sgcu/rnkm65-file/src/app/utils"
+	"github.com/isd-sgcu/rnkm65-file/src/config"
+	"github.com/rs/zerolog/log"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 100
+	defaultRetention    = 7 * 24 * time.Hour
+	defaultClaimTTL     = time.Minute
+	publishTimeout      = 10 * time.Second
+)
+
+// Relay moves the events from the outbox to the bus, an event is published at least once and the replicas
+// publish their batches side by side, so the consumers have to drop the ids they have seen and order by the version
+type Relay struct {
+	conf       config.Outbox
+	repository IRepository
+	bus        IBus
+	pruned     time.Time
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+type IRepository interface {
+	Relay(int, time.Duration, func(*outbox.Event) error) (int, error)
+	DeletePublished(time.Time) (int64, error)
+}
+
+type IBus interface {
+	Publish(context.Context, *dto.Message) error
+}
+
+func NewRelay(conf config.Outbox, repository IRepository, bus IBus) *Relay {
+	return &Relay{
+		conf:       conf,
+		repository: repository,
+		bus:        bus,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Start polls the outbox until Stop is called
+func (r *Relay) Start() {
+	go func() {
+		defer close(r.done)
+
+		ticker := time.NewTicker(r.pollInterval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.poll()
+			}
+		}
+	}()
+}
+
+// Stop waits for the batch in flight, the events left are published by the next start
+func (r *Relay) Stop() {
+	close(r.stop)
+	<-r.done
+}
+
+// RelayOnce publishes one batch of the events and returns how many were published
+func (r *Relay) RelayOnce() (int, error) {
+	return r.repository.Relay(r.batchSize(), r.claimTTL(), func(e *outbox.Event) error {
+		ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+		defer cancel()
+
+		return r.bus.Publish(ctx, &dto.Message{
+			ID:         strconv.FormatUint(e.ID, 10),
+			Type:       e.Type,
+			Key:        e.AggregateID,
+			Payload:    []byte(e.Payload),
+			OccurredAt: e.CreatedAt,
+		})
+	})
+}
+
+// poll relays and prunes once, a panic is logged and the next poll runs as usual
+func (r *Relay) poll() {
+	defer utils.Recover("outbox relay")
+
+	r.drain()
+	r.prune()
+}
+
+// drain keeps relaying while the batches come back full so a backlog does not wait for the next tick
+func (r *Relay) drain() {
+	for {
+		select {
+		case <-r.stop:
+			return
+		default:
+		}
+
+		published, err := r.RelayOnce()
+		if err != nil {
+			log.Error().
+				Err(err).
+				Str("service", "file").
+				Str("module", "outbox relay").
+				Int("published", published).
+				Msg("Error while relaying the events, retrying on the next poll")
+			return
+		}
+
+		if published < r.batchSize() {
+			return
+		}
+	}
+}
+
+// prune removes the published events older than the retention, it runs at most once an hour
+func (r *Relay) prune() {
+	if time.Since(r.pruned) < time.Hour {
+		return
+	}
+	r.pruned = time.Now()
+
+	deleted, err := r.repository.DeletePublished(time.Now().Add(-r.retention()))
+	if err != nil {
+		log.Error().
+			Err(err).
+			Str("service", "file").
+			Str("module", "outbox relay").
+			Msg("Error while deleting the published events")
+		return
+	}
+
+	if deleted > 0 {
+		log.Info().
+			Str("service", "file").
+			Str("module", "outbox relay").
+			Msgf("Deleted %v published events", deleted)
+	}
+}
+
+func (r *Relay) pollInterval() time.Duration {
+	if r.conf.PollInterval > 0 {
+		return time.Duration(r.conf.PollInterval) * time.Millisecond
+	}
+
+	return defaultPollInterval
+}
+
+func (r *Relay) batchSize() int {
+	if r.conf.BatchSize > 0 {
+		return r.conf.BatchSize
+	}
+
+	return defaultBatchSize
+}
+
+func (r *Relay) claimTTL() time.Duration {
+	if r.conf.ClaimTTL > 0 {
+		return time.Duration(r.conf.ClaimTTL) * time.Second
+	}
+
+	return defaultClaimTTL
+}
+
+func (r *Relay) retention() time.Duration {
+	if r.conf.Retention > 0 {
+		return time.Duration(r.conf.Retention) * time.Second
+	}
+
+	return defaultRetention
+}
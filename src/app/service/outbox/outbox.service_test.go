@@ -0,0 +1,110 @@
+package outbox
+
+import (
+	"errors"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/event"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/outbox"
+	"github.com/isd-sgcu/rnkm65-file/src/client/bus"
+	"github.com/isd-sgcu/rnkm65-file/src/config"
+	bMock "github.com/isd-sgcu/rnkm65-file/src/mocks/bus"
+	oMock "github.com/isd-sgcu/rnkm65-file/src/mocks/outbox"
+	"github.com/stretchr/testify/assert"
+	tMock "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"testing"
+	"time"
+)
+
+type RelayTest struct {
+	suite.Suite
+	conf   config.Outbox
+	events []outbox.Event
+}
+
+func TestRelay(t *testing.T) {
+	suite.Run(t, new(RelayTest))
+}
+
+func (t *RelayTest) SetupTest() {
+	t.conf = config.Outbox{Enabled: true, BatchSize: 2, PollInterval: 10}
+
+	createdAt := time.Now().Truncate(time.Second)
+	t.events = []outbox.Event{
+		{ID: 1, Type: "FileUploaded", AggregateID: "file-1", Payload: `{"id":"file-1"}`, CreatedAt: createdAt},
+		{ID: 2, Type: "FileReplaced", AggregateID: "file-1", Payload: `{"id":"file-1"}`, CreatedAt: createdAt},
+	}
+}
+
+func (t *RelayTest) TestRelayOnceSuccess() {
+	repo := oMock.RepositoryMock{}
+	repo.On("Relay", 2, defaultClaimTTL).Return(t.events, nil)
+
+	eventBus := bus.NewMemoryClient()
+
+	var received []*dto.Message
+	eventBus.Subscribe(func(msg *dto.Message) {
+		received = append(received, msg)
+	})
+
+	published, err := NewRelay(t.conf, &repo, eventBus).RelayOnce()
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), 2, published)
+	assert.Equal(t.T(), []*dto.Message{
+		{ID: "1", Type: "FileUploaded", Key: "file-1", Payload: []byte(`{"id":"file-1"}`), OccurredAt: t.events[0].CreatedAt},
+		{ID: "2", Type: "FileReplaced", Key: "file-1", Payload: []byte(`{"id":"file-1"}`), OccurredAt: t.events[1].CreatedAt},
+	}, received)
+}
+
+func (t *RelayTest) TestRelayOnceStopsAtPublishError() {
+	repo := oMock.RepositoryMock{}
+	repo.On("Relay", 2, defaultClaimTTL).Return(t.events, nil)
+
+	eventBus := bMock.ClientMock{}
+	eventBus.On("Publish", tMock.MatchedBy(func(msg *dto.Message) bool { return msg.ID == "1" })).Return(nil)
+	eventBus.On("Publish", tMock.MatchedBy(func(msg *dto.Message) bool { return msg.ID == "2" })).Return(errors.New("Something wrong :("))
+
+	published, err := NewRelay(t.conf, &repo, &eventBus).RelayOnce()
+
+	assert.NotNil(t.T(), err)
+	assert.Equal(t.T(), 1, published)
+}
+
+func (t *RelayTest) TestStartDrainsFullBatches() {
+	repo := oMock.RepositoryMock{}
+	repo.On("Relay", 2, defaultClaimTTL).Return(t.events, nil).Once()
+	repo.On("Relay", 2, defaultClaimTTL).Return(t.events[:1], nil).Once()
+	repo.On("Relay", 2, defaultClaimTTL).Return([]outbox.Event{}, nil)
+	repo.On("DeletePublished", tMock.Anything).Return(int64(0), nil)
+
+	eventBus := bus.NewMemoryClient()
+
+	received := make(chan *dto.Message, 3)
+	eventBus.Subscribe(func(msg *dto.Message) {
+		received <- msg
+	})
+
+	relay := NewRelay(t.conf, &repo, eventBus)
+	relay.Start()
+
+	for i := 0; i < 3; i++ {
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			t.T().Fatal("the events were not relayed")
+		}
+	}
+
+	relay.Stop()
+
+	repo.AssertCalled(t.T(), "DeletePublished", tMock.Anything)
+}
+
+func (t *RelayTest) TestPollRecoversPanic() {
+	repo := oMock.RepositoryMock{}
+	repo.On("Relay", 2, defaultClaimTTL).Run(func(tMock.Arguments) { panic("Something wrong :(") })
+
+	relay := NewRelay(t.conf, &repo, bus.NewMemoryClient())
+
+	assert.NotPanics(t.T(), relay.poll)
+}
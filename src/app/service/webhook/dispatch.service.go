@@ -0,0 +1,242 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/webhook"
+	"github.com/isd-sgcu/rnkm65-file/src/app/utils"
+	constant "github.com/isd-sgcu/rnkm65-file/src/constant/webhook"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	SignatureHeader = "X-Webhook-Signature"
+	EventIDHeader   = "X-Webhook-Id"
+	EventTypeHeader = "X-Webhook-Event"
+)
+
+// Start posts the due deliveries until Stop is called
+func (s *Service) Start() {
+	go func() {
+		defer close(s.done)
+
+		ticker := time.NewTicker(s.pollInterval())
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.stop:
+				return
+			case <-ticker.C:
+				s.poll()
+			}
+		}
+	}()
+}
+
+// poll dispatches one batch, a panic is logged and its claimed deliveries are retried once their lease is over
+func (s *Service) poll() {
+	defer utils.Recover("webhook dispatcher")
+
+	if _, err := s.DispatchOnce(); err != nil {
+		log.Error().
+			Err(err).
+			Str("service", "file").
+			Str("module", "webhook dispatcher").
+			Msg("Error while claiming the deliveries, retrying on the next poll")
+	}
+}
+
+// Stop waits for the batch in flight, its failed deliveries are retried once their lease is over
+func (s *Service) Stop() {
+	close(s.stop)
+	<-s.done
+}
+
+// DispatchOnce posts one batch of the due deliveries and returns how many were delivered
+func (s *Service) DispatchOnce() (int, error) {
+	var deliveries []webhook.Delivery
+	err := s.repository.ClaimDeliveries(s.batchSize(), s.lease(), &deliveries)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for i := range deliveries {
+		d := &deliveries[i]
+
+		if s.deliver(d) {
+			delivered++
+		}
+
+		if err := s.repository.UpdateDelivery(d); err != nil {
+			log.Error().
+				Err(err).
+				Str("module", "webhook dispatcher").
+				Str("delivery_id", d.ID.String()).
+				Str("status", d.Status).
+				Msg("Error while saving delivery data")
+		}
+	}
+
+	return delivered, nil
+}
+
+// deliver posts the delivery and records the outcome on it, it is dead once the attempts run out
+// or the subscription is gone
+func (s *Service) deliver(d *webhook.Delivery) bool {
+	d.Attempts++
+
+	sub, err := s.subscription(d.SubscriptionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			d.Status = string(constant.DEAD)
+			d.LastError = "Not found subscription"
+			return false
+		}
+
+		s.fail(d, 0, err)
+		return false
+	}
+
+	timestamp := time.Now().Unix()
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(EventIDHeader, d.EventID)
+	header.Set(EventTypeHeader, d.EventType)
+	header.Set(SignatureHeader, Sign(sub.Secret, timestamp, []byte(d.Payload)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout())
+	defer cancel()
+
+	code, err := s.client.Post(ctx, sub.Url, header, []byte(d.Payload))
+	if err == nil && (code < 200 || code > 299) {
+		err = fmt.Errorf("Unexpected status code %v", code)
+	}
+
+	if err != nil {
+		s.fail(d, code, err)
+		return false
+	}
+
+	now := time.Now()
+	d.Status = string(constant.DELIVERED)
+	d.LastStatusCode = code
+	d.LastError = ""
+	d.DeliveredAt = &now
+
+	return true
+}
+
+// fail schedules the next attempt with an exponential backoff
+func (s *Service) fail(d *webhook.Delivery, code int, err error) {
+	d.LastStatusCode = code
+	d.LastError = err.Error()
+
+	if d.Attempts >= s.maxAttempts() {
+		d.Status = string(constant.DEAD)
+		log.Warn().
+			Err(err).
+			Str("module", "webhook dispatcher").
+			Str("delivery_id", d.ID.String()).
+			Str("subscription_id", d.SubscriptionID).
+			Int("attempts", d.Attempts).
+			Msg("Webhook delivery is dead")
+		return
+	}
+
+	d.Status = string(constant.PENDING)
+	d.NextAttemptAt = time.Now().Add(s.backoff(d.Attempts))
+}
+
+func (s *Service) subscription(id string) (*webhook.Subscription, error) {
+	if sub, ok := s.static[id]; ok {
+		return sub, nil
+	}
+
+	sub := &webhook.Subscription{}
+	if err := s.repository.FindSubscriptionByID(id, sub); err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+// Sign returns the signature header of the payload, the subscriber recomputes the hmac sha256
+// of "<t>.<body>" with the secret and rejects the old timestamps to stop the replays
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// backoff doubles with every failed attempt up to the max backoff
+func (s *Service) backoff(attempts int) time.Duration {
+	backoff := defaultBackoff
+	if s.conf.Backoff > 0 {
+		backoff = time.Duration(s.conf.Backoff) * time.Second
+	}
+
+	maxBackoff := defaultMaxBackoff
+	if s.conf.MaxBackoff > 0 {
+		maxBackoff = time.Duration(s.conf.MaxBackoff) * time.Second
+	}
+
+	for i := 1; i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	return backoff
+}
+
+// lease keeps the claimed deliveries from the other dispatchers until the whole batch could have timed out
+func (s *Service) lease() time.Duration {
+	return time.Duration(s.batchSize()+1) * s.timeout()
+}
+
+func (s *Service) maxAttempts() int {
+	if s.conf.MaxAttempts > 0 {
+		return s.conf.MaxAttempts
+	}
+
+	return defaultMaxAttempts
+}
+
+func (s *Service) timeout() time.Duration {
+	if s.conf.Timeout > 0 {
+		return time.Duration(s.conf.Timeout) * time.Second
+	}
+
+	return defaultTimeout
+}
+
+func (s *Service) pollInterval() time.Duration {
+	if s.conf.PollInterval > 0 {
+		return time.Duration(s.conf.PollInterval) * time.Millisecond
+	}
+
+	return defaultPollInterval
+}
+
+func (s *Service) batchSize() int {
+	if s.conf.BatchSize > 0 {
+		return s.conf.BatchSize
+	}
+
+	return defaultBatchSize
+}
@@ -0,0 +1,414 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/isd-sgcu/rnkm65-file/src/app/apperror"
+	"github.com/isd-sgcu/rnkm65-file/src/app/auth"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/event"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/webhook"
+	"github.com/isd-sgcu/rnkm65-file/src/config"
+	"github.com/isd-sgcu/rnkm65-file/src/constant/event"
+	constant "github.com/isd-sgcu/rnkm65-file/src/constant/webhook"
+	"github.com/isd-sgcu/rnkm65-file/src/proto"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	staticPrefix        = "static:"
+	secretSize          = 32
+	defaultListLimit    = 100
+	maxListLimit        = 1000
+	defaultMaxAttempts  = 8
+	defaultBackoff      = 10 * time.Second
+	defaultMaxBackoff   = time.Hour
+	defaultTimeout      = 10 * time.Second
+	defaultPollInterval = time.Second
+	defaultBatchSize    = 50
+)
+
+var eventTypes = []event.Type{event.FILE_UPLOADED, event.FILE_REPLACED, event.FILE_DELETED}
+
+type Service struct {
+	conf       config.Webhook
+	authConf   config.Auth
+	repository IRepository
+	client     IClient
+	static     map[string]*webhook.Subscription
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+type IRepository interface {
+	FindSubscriptions(*[]webhook.Subscription) error
+	FindSubscriptionByID(string, *webhook.Subscription) error
+	CreateSubscription(*webhook.Subscription) error
+	DeleteSubscription(string) error
+	CreateDeliveries([]webhook.Delivery) error
+	ClaimDeliveries(int, time.Duration, *[]webhook.Delivery) error
+	UpdateDelivery(*webhook.Delivery) error
+	FindDeliveries(string, string, int, *[]webhook.Delivery) error
+	RetryDelivery(string, *webhook.Delivery) error
+}
+
+type IClient interface {
+	Post(context.Context, string, http.Header, []byte) (int, error)
+}
+
+// Payload is the json body posted to the subscribers
+type Payload struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+}
+
+func NewService(conf config.Webhook, authConf config.Auth, repository IRepository, client IClient) *Service {
+	static := map[string]*webhook.Subscription{}
+	for _, sub := range conf.Subscriptions {
+		static[staticPrefix+sub.Name] = &webhook.Subscription{
+			Url:    sub.Url,
+			Secret: sub.Secret,
+			Events: strings.Join(sub.Events, ","),
+		}
+	}
+
+	return &Service{
+		conf:       conf,
+		authConf:   authConf,
+		repository: repository,
+		client:     client,
+		static:     static,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// Publish queues a delivery of the event to every subscription of its type, it is the bus the outbox relays to
+func (s *Service) Publish(_ context.Context, msg *dto.Message) error {
+	subscriptions, err := s.subscriptions()
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(&Payload{
+		ID:         msg.ID,
+		Type:       msg.Type,
+		OccurredAt: msg.OccurredAt,
+		Data:       msg.Payload,
+	})
+	if err != nil {
+		return err
+	}
+
+	var deliveries []webhook.Delivery
+	for id, sub := range subscriptions {
+		if !subscribed(sub, msg.Type) {
+			continue
+		}
+
+		deliveries = append(deliveries, webhook.Delivery{
+			SubscriptionID: id,
+			EventID:        msg.ID,
+			EventType:      msg.Type,
+			Payload:        string(payload),
+			Status:         string(constant.PENDING),
+			NextAttemptAt:  time.Now(),
+		})
+	}
+
+	return s.repository.CreateDeliveries(deliveries)
+}
+
+func (s *Service) CreateWebhookSubscription(ctx context.Context, req *proto.CreateWebhookSubscriptionRequest) (*proto.CreateWebhookSubscriptionResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(req.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, apperror.InvalidArgument("url", "Url must be an absolute http or https url")
+	}
+
+	for i, e := range req.Events {
+		if !validEvent(e) {
+			return nil, apperror.InvalidArgument(fmt.Sprintf("events[%v]", i), fmt.Sprintf("Unknown event %v", e))
+		}
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		secret, err = newSecret()
+		if err != nil {
+			log.Ctx(ctx).Error().
+				Err(err).
+				Str("module", "create webhook subscription").
+				Msg("Cannot generate the secret")
+			return nil, apperror.Internal(err)
+		}
+	}
+
+	sub := &webhook.Subscription{
+		Url:    req.Url,
+		Secret: secret,
+		Events: strings.Join(req.Events, ","),
+	}
+
+	if caller, ok := auth.FromContext(ctx); ok {
+		sub.CreatedBy = caller.Subject
+		if sub.CreatedBy == "" {
+			sub.CreatedBy = caller.Service
+		}
+	}
+
+	err = s.repository.CreateSubscription(sub)
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "create webhook subscription").
+			Str("url", req.Url).
+			Msg("Error while saving subscription data")
+		return nil, apperror.Unavailable(apperror.Database, err)
+	}
+
+	return &proto.CreateWebhookSubscriptionResponse{
+		Subscription: rawToSubscription(sub.ID.String(), sub, false),
+		Secret:       secret,
+	}, nil
+}
+
+func (s *Service) DeleteWebhookSubscription(ctx context.Context, req *proto.DeleteWebhookSubscriptionRequest) (*proto.DeleteWebhookSubscriptionResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(req.Id, staticPrefix) {
+		return nil, apperror.FailedPrecondition("STATIC", "subscription:"+req.Id, "The subscriptions from the config cannot be deleted")
+	}
+
+	err := s.repository.DeleteSubscription(req.Id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFound("subscription", req.Id)
+		}
+
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "delete webhook subscription").
+			Str("subscription_id", req.Id).
+			Msg("Error while deleting subscription data")
+		return nil, apperror.Unavailable(apperror.Database, err)
+	}
+
+	return &proto.DeleteWebhookSubscriptionResponse{}, nil
+}
+
+func (s *Service) ListWebhookSubscriptions(ctx context.Context, _ *proto.ListWebhookSubscriptionsRequest) (*proto.ListWebhookSubscriptionsResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	var subs []webhook.Subscription
+	err := s.repository.FindSubscriptions(&subs)
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "list webhook subscriptions").
+			Msg("Error while trying to query data")
+		return nil, apperror.Unavailable(apperror.Database, err)
+	}
+
+	result := make([]*proto.WebhookSubscription, 0, len(s.conf.Subscriptions)+len(subs))
+	for _, sub := range s.conf.Subscriptions {
+		id := staticPrefix + sub.Name
+		result = append(result, rawToSubscription(id, s.static[id], true))
+	}
+	for i := range subs {
+		result = append(result, rawToSubscription(subs[i].ID.String(), &subs[i], false))
+	}
+
+	return &proto.ListWebhookSubscriptionsResponse{Subscriptions: result}, nil
+}
+
+// ListWebhookDeliveries is the dead letter view when the status is dead
+func (s *Service) ListWebhookDeliveries(ctx context.Context, req *proto.ListWebhookDeliveriesRequest) (*proto.ListWebhookDeliveriesResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	if req.Status != "" && !validStatus(req.Status) {
+		return nil, apperror.InvalidArgument("status", fmt.Sprintf("Unknown status %v", req.Status))
+	}
+
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if limit > maxListLimit {
+		limit = maxListLimit
+	}
+
+	var deliveries []webhook.Delivery
+	err := s.repository.FindDeliveries(req.SubscriptionId, req.Status, limit, &deliveries)
+	if err != nil {
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "list webhook deliveries").
+			Str("subscription_id", req.SubscriptionId).
+			Msg("Error while trying to query data")
+		return nil, apperror.Unavailable(apperror.Database, err)
+	}
+
+	result := make([]*proto.WebhookDelivery, 0, len(deliveries))
+	for i := range deliveries {
+		result = append(result, rawToDelivery(&deliveries[i]))
+	}
+
+	return &proto.ListWebhookDeliveriesResponse{Deliveries: result}, nil
+}
+
+// RetryWebhookDelivery queues a dead delivery again
+func (s *Service) RetryWebhookDelivery(ctx context.Context, req *proto.RetryWebhookDeliveryRequest) (*proto.RetryWebhookDeliveryResponse, error) {
+	if err := s.authorize(ctx); err != nil {
+		return nil, err
+	}
+
+	d := &webhook.Delivery{}
+	err := s.repository.RetryDelivery(req.Id, d)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, apperror.NotFound("delivery", req.Id)
+		}
+
+		log.Ctx(ctx).Error().
+			Err(err).
+			Str("module", "retry webhook delivery").
+			Str("delivery_id", req.Id).
+			Msg("Error while updating delivery data")
+		return nil, apperror.Unavailable(apperror.Database, err)
+	}
+
+	return &proto.RetryWebhookDeliveryResponse{Delivery: rawToDelivery(d)}, nil
+}
+
+// authorize only allows the admins to manage the webhooks
+func (s *Service) authorize(ctx context.Context) error {
+	if !s.authConf.Enabled {
+		return nil
+	}
+
+	caller, ok := auth.FromContext(ctx)
+	if ok && caller.Can(auth.Admin) {
+		return nil
+	}
+
+	return apperror.PermissionDenied(string(auth.Admin))
+}
+
+// subscriptions returns the subscriptions from the config and the database keyed by their id
+func (s *Service) subscriptions() (map[string]*webhook.Subscription, error) {
+	var subs []webhook.Subscription
+	if err := s.repository.FindSubscriptions(&subs); err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*webhook.Subscription, len(s.static)+len(subs))
+	for id, sub := range s.static {
+		result[id] = sub
+	}
+	for i := range subs {
+		result[subs[i].ID.String()] = &subs[i]
+	}
+
+	return result, nil
+}
+
+func subscribed(sub *webhook.Subscription, eventType string) bool {
+	if sub.Events == "" {
+		return true
+	}
+
+	for _, e := range strings.Split(sub.Events, ",") {
+		if e == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validEvent(eventType string) bool {
+	for _, e := range eventTypes {
+		if string(e) == eventType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validStatus(s string) bool {
+	switch constant.Status(s) {
+	case constant.PENDING, constant.DELIVERED, constant.DEAD:
+		return true
+	default:
+		return false
+	}
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, secretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+func rawToSubscription(id string, in *webhook.Subscription, static bool) *proto.WebhookSubscription {
+	result := &proto.WebhookSubscription{
+		Id:     id,
+		Url:    in.Url,
+		Static: static,
+	}
+
+	if in.Events != "" {
+		result.Events = strings.Split(in.Events, ",")
+	}
+
+	if !in.CreatedAt.IsZero() {
+		result.CreatedAt = in.CreatedAt.Unix()
+	}
+
+	return result
+}
+
+func rawToDelivery(in *webhook.Delivery) *proto.WebhookDelivery {
+	result := &proto.WebhookDelivery{
+		Id:             in.ID.String(),
+		SubscriptionId: in.SubscriptionID,
+		EventId:        in.EventID,
+		EventType:      in.EventType,
+		Status:         in.Status,
+		Attempts:       int32(in.Attempts),
+		LastStatusCode: int32(in.LastStatusCode),
+		LastError:      in.LastError,
+		NextAttemptAt:  in.NextAttemptAt.Unix(),
+		CreatedAt:      in.CreatedAt.Unix(),
+	}
+
+	if in.DeliveredAt != nil {
+		result.DeliveredAt = in.DeliveredAt.Unix()
+	}
+
+	return result
+}
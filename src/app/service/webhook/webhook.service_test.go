@@ -0,0 +1,316 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/isd-sgcu/rnkm65-file/src/app/auth"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/event"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/webhook"
+	"github.com/isd-sgcu/rnkm65-file/src/config"
+	constant "github.com/isd-sgcu/rnkm65-file/src/constant/webhook"
+	wMock "github.com/isd-sgcu/rnkm65-file/src/mocks/webhook"
+	"github.com/isd-sgcu/rnkm65-file/src/proto"
+	"github.com/stretchr/testify/assert"
+	tMock "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type WebhookServiceTest struct {
+	suite.Suite
+	conf     config.Webhook
+	authConf config.Auth
+	sub      *webhook.Subscription
+	msg      *dto.Message
+	delivery *webhook.Delivery
+	err      error
+}
+
+func TestWebhookService(t *testing.T) {
+	suite.Run(t, new(WebhookServiceTest))
+}
+
+func (t *WebhookServiceTest) SetupTest() {
+	t.conf = config.Webhook{
+		Subscriptions: []config.WebhookSubscription{
+			{Name: "checkin", Url: "https://checkin.test/webhooks", Secret: "static-secret", Events: []string{"FileUploaded"}},
+		},
+		MaxAttempts: 3,
+		Backoff:     10,
+		MaxBackoff:  30,
+	}
+
+	t.authConf = config.Auth{}
+
+	t.sub = &webhook.Subscription{
+		Base:   model.Base{ID: uuid.New()},
+		Url:    "https://user.test/webhooks",
+		Secret: "secret",
+	}
+
+	t.msg = &dto.Message{
+		ID:         "1",
+		Type:       "FileReplaced",
+		Key:        uuid.New().String(),
+		Payload:    []byte(`{"id":"file"}`),
+		OccurredAt: time.Now().UTC().Truncate(time.Second),
+	}
+
+	t.delivery = &webhook.Delivery{
+		Base:           model.Base{ID: uuid.New()},
+		SubscriptionID: t.sub.ID.String(),
+		EventID:        "1",
+		EventType:      "FileReplaced",
+		Payload:        `{"id":"1"}`,
+		Status:         string(constant.PENDING),
+	}
+
+	t.err = errors.New("Something wrong :(")
+}
+
+func (t *WebhookServiceTest) TestPublishQueuesMatchingSubscriptions() {
+	repo := wMock.RepositoryMock{}
+	repo.On("FindSubscriptions").Return([]webhook.Subscription{*t.sub}, nil)
+	repo.On("CreateDeliveries", tMock.MatchedBy(func(in []webhook.Delivery) bool {
+		return len(in) == 1 && in[0].SubscriptionID == t.sub.ID.String() && in[0].EventID == "1" && in[0].Status == "pending"
+	})).Return(nil)
+
+	srv := NewService(t.conf, t.authConf, &repo, &wMock.ClientMock{})
+
+	err := srv.Publish(context.Background(), t.msg)
+
+	assert.Nil(t.T(), err)
+	repo.AssertExpectations(t.T())
+}
+
+func (t *WebhookServiceTest) TestPublishPayload() {
+	var payload Payload
+
+	repo := wMock.RepositoryMock{}
+	repo.On("FindSubscriptions").Return([]webhook.Subscription{}, nil)
+	repo.On("CreateDeliveries", tMock.MatchedBy(func(in []webhook.Delivery) bool {
+		return len(in) == 1 && in[0].SubscriptionID == "static:checkin" && json.Unmarshal([]byte(in[0].Payload), &payload) == nil
+	})).Return(nil)
+
+	srv := NewService(t.conf, t.authConf, &repo, &wMock.ClientMock{})
+
+	t.msg.Type = "FileUploaded"
+	err := srv.Publish(context.Background(), t.msg)
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), Payload{ID: "1", Type: "FileUploaded", OccurredAt: t.msg.OccurredAt, Data: t.msg.Payload}, payload)
+}
+
+func (t *WebhookServiceTest) TestDispatchOnceSignsPayload() {
+	repo := wMock.RepositoryMock{}
+	repo.On("ClaimDeliveries", defaultBatchSize).Return([]webhook.Delivery{*t.delivery}, nil)
+	repo.On("FindSubscriptionByID", t.sub.ID.String()).Return(t.sub, nil)
+	repo.On("UpdateDelivery", t.delivery.ID.String(), "delivered", 1).Return(nil)
+
+	client := wMock.ClientMock{}
+	client.On("Post", t.sub.Url).Return(204, nil)
+
+	srv := NewService(t.conf, t.authConf, &repo, &client)
+
+	delivered, err := srv.DispatchOnce()
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), 1, delivered)
+	assert.Equal(t.T(), []byte(t.delivery.Payload), client.Body)
+	assert.Equal(t.T(), "1", client.Header.Get(EventIDHeader))
+	assert.Equal(t.T(), "FileReplaced", client.Header.Get(EventTypeHeader))
+
+	signature := client.Header.Get(SignatureHeader)
+	timestamp, err := strconv.ParseInt(strings.TrimPrefix(strings.Split(signature, ",")[0], "t="), 10, 64)
+	assert.Nil(t.T(), err)
+	assert.True(t.T(), hmac.Equal([]byte(Sign("secret", timestamp, client.Body)), []byte(signature)))
+	repo.AssertExpectations(t.T())
+}
+
+func (t *WebhookServiceTest) TestDispatchOnceRetriesWithBackoff() {
+	t.delivery.Attempts = 1
+
+	repo := wMock.RepositoryMock{}
+	repo.On("ClaimDeliveries", defaultBatchSize).Return([]webhook.Delivery{*t.delivery}, nil)
+	repo.On("FindSubscriptionByID", t.sub.ID.String()).Return(t.sub, nil)
+	repo.On("UpdateDelivery", t.delivery.ID.String(), "pending", 2).Return(nil)
+
+	client := wMock.ClientMock{}
+	client.On("Post", t.sub.Url).Return(500, nil)
+
+	srv := NewService(t.conf, t.authConf, &repo, &client)
+
+	delivered, err := srv.DispatchOnce()
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), 0, delivered)
+	repo.AssertExpectations(t.T())
+}
+
+func (t *WebhookServiceTest) TestDispatchOnceDeadAfterMaxAttempts() {
+	t.delivery.Attempts = 2
+
+	repo := wMock.RepositoryMock{}
+	repo.On("ClaimDeliveries", defaultBatchSize).Return([]webhook.Delivery{*t.delivery}, nil)
+	repo.On("FindSubscriptionByID", t.sub.ID.String()).Return(t.sub, nil)
+	repo.On("UpdateDelivery", t.delivery.ID.String(), "dead", 3).Return(nil)
+
+	client := wMock.ClientMock{}
+	client.On("Post", t.sub.Url).Return(0, t.err)
+
+	srv := NewService(t.conf, t.authConf, &repo, &client)
+
+	_, err := srv.DispatchOnce()
+
+	assert.Nil(t.T(), err)
+	repo.AssertExpectations(t.T())
+}
+
+func (t *WebhookServiceTest) TestDispatchOnceDeletedSubscription() {
+	repo := wMock.RepositoryMock{}
+	repo.On("ClaimDeliveries", defaultBatchSize).Return([]webhook.Delivery{*t.delivery}, nil)
+	repo.On("FindSubscriptionByID", t.sub.ID.String()).Return(nil, gorm.ErrRecordNotFound)
+	repo.On("UpdateDelivery", t.delivery.ID.String(), "dead", 1).Return(nil)
+
+	client := wMock.ClientMock{}
+
+	srv := NewService(t.conf, t.authConf, &repo, &client)
+
+	_, err := srv.DispatchOnce()
+
+	assert.Nil(t.T(), err)
+	client.AssertNotCalled(t.T(), "Post", tMock.Anything)
+}
+
+func (t *WebhookServiceTest) TestBackoff() {
+	srv := NewService(t.conf, t.authConf, &wMock.RepositoryMock{}, &wMock.ClientMock{})
+
+	for attempts, want := range map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 3: 30 * time.Second, 10: 30 * time.Second} {
+		assert.Equal(t.T(), want, srv.backoff(attempts), fmt.Sprintf("attempts %v", attempts))
+	}
+}
+
+func (t *WebhookServiceTest) TestCreateWebhookSubscriptionSuccess() {
+	repo := wMock.RepositoryMock{}
+	repo.On("CreateSubscription", t.sub.Url, "FileUploaded,FileDeleted").Return(t.sub, nil)
+
+	srv := NewService(t.conf, t.authConf, &repo, &wMock.ClientMock{})
+
+	actual, err := srv.CreateWebhookSubscription(context.Background(), &proto.CreateWebhookSubscriptionRequest{
+		Url:    t.sub.Url,
+		Events: []string{"FileUploaded", "FileDeleted"},
+	})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), t.sub.ID.String(), actual.Subscription.Id)
+	assert.Len(t.T(), actual.Secret, 2*secretSize)
+}
+
+func (t *WebhookServiceTest) TestCreateWebhookSubscriptionInvalidArgument() {
+	repo := wMock.RepositoryMock{}
+
+	srv := NewService(t.conf, t.authConf, &repo, &wMock.ClientMock{})
+
+	for _, req := range []*proto.CreateWebhookSubscriptionRequest{
+		{Url: "ftp://user.test/webhooks"},
+		{Url: "/webhooks"},
+		{Url: t.sub.Url, Events: []string{"FileRenamed"}},
+	} {
+		actual, err := srv.CreateWebhookSubscription(context.Background(), req)
+
+		st, ok := status.FromError(err)
+
+		assert.True(t.T(), ok)
+		assert.Nil(t.T(), actual)
+		assert.Equal(t.T(), codes.InvalidArgument, st.Code())
+	}
+	repo.AssertNotCalled(t.T(), "CreateSubscription", tMock.Anything, tMock.Anything)
+}
+
+func (t *WebhookServiceTest) TestCreateWebhookSubscriptionNotAdmin() {
+	t.authConf.Enabled = true
+
+	srv := NewService(t.conf, t.authConf, &wMock.RepositoryMock{}, &wMock.ClientMock{})
+
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "checkin", Permissions: []auth.Permission{auth.Read}})
+
+	actual, err := srv.CreateWebhookSubscription(ctx, &proto.CreateWebhookSubscriptionRequest{Url: t.sub.Url})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.PermissionDenied, st.Code())
+}
+
+func (t *WebhookServiceTest) TestDeleteStaticWebhookSubscription() {
+	repo := wMock.RepositoryMock{}
+
+	srv := NewService(t.conf, t.authConf, &repo, &wMock.ClientMock{})
+
+	actual, err := srv.DeleteWebhookSubscription(context.Background(), &proto.DeleteWebhookSubscriptionRequest{Id: "static:checkin"})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.FailedPrecondition, st.Code())
+	repo.AssertNotCalled(t.T(), "DeleteSubscription", tMock.Anything)
+}
+
+func (t *WebhookServiceTest) TestListWebhookSubscriptions() {
+	repo := wMock.RepositoryMock{}
+	repo.On("FindSubscriptions").Return([]webhook.Subscription{*t.sub}, nil)
+
+	srv := NewService(t.conf, t.authConf, &repo, &wMock.ClientMock{})
+
+	actual, err := srv.ListWebhookSubscriptions(context.Background(), &proto.ListWebhookSubscriptionsRequest{})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), []*proto.WebhookSubscription{
+		{Id: "static:checkin", Url: "https://checkin.test/webhooks", Events: []string{"FileUploaded"}, Static: true},
+		{Id: t.sub.ID.String(), Url: t.sub.Url},
+	}, actual.Subscriptions)
+}
+
+func (t *WebhookServiceTest) TestListDeadWebhookDeliveries() {
+	t.delivery.Status = string(constant.DEAD)
+
+	repo := wMock.RepositoryMock{}
+	repo.On("FindDeliveries", "", "dead", defaultListLimit).Return([]webhook.Delivery{*t.delivery}, nil)
+
+	srv := NewService(t.conf, t.authConf, &repo, &wMock.ClientMock{})
+
+	actual, err := srv.ListWebhookDeliveries(context.Background(), &proto.ListWebhookDeliveriesRequest{Status: "dead"})
+
+	assert.Nil(t.T(), err)
+	assert.Len(t.T(), actual.Deliveries, 1)
+	assert.Equal(t.T(), t.delivery.ID.String(), actual.Deliveries[0].Id)
+	assert.Equal(t.T(), "dead", actual.Deliveries[0].Status)
+}
+
+func (t *WebhookServiceTest) TestRetryWebhookDeliveryNotFound() {
+	repo := wMock.RepositoryMock{}
+	repo.On("RetryDelivery", t.delivery.ID.String()).Return(nil, gorm.ErrRecordNotFound)
+
+	srv := NewService(t.conf, t.authConf, &repo, &wMock.ClientMock{})
+
+	actual, err := srv.RetryWebhookDelivery(context.Background(), &proto.RetryWebhookDeliveryRequest{Id: t.delivery.ID.String()})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Nil(t.T(), actual)
+	assert.Equal(t.T(), codes.NotFound, st.Code())
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	DefaultCachePrefix  = "rnkm65-file"
+	DefaultCacheVersion = 2
+)
+
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+// CacheKey builds the redis keys in the form of <prefix>:v<version>:<kind>:owner:<owner id>,
+// bump the version whenever the cached value format changes so the old entries are never read
+type CacheKey struct {
+	prefix  string
+	version int
+}
+
+func NewCacheKey(prefix string, version int) *CacheKey {
+	if prefix == "" {
+		prefix = DefaultCachePrefix
+	}
+
+	if version <= 0 {
+		version = DefaultCacheVersion
+	}
+
+	return &CacheKey{
+		prefix:  prefix,
+		version: version,
+	}
+}
+
+func (k *CacheKey) File(ownerID string) string {
+	return k.build("file", ownerID)
+}
+
+func (k *CacheKey) Lock(ownerID string) string {
+	return k.build("lock", ownerID)
+}
+
+// Downloads is the download counter of the share link, it is not a cache entry and is not matched by the owner pattern
+func (k *CacheKey) Downloads(linkID string) string {
+	return fmt.Sprintf("%s:v%d:downloads:link:%s", k.prefix, k.version, linkID)
+}
+
+// Download is the download proxy token, it is keyed by the hash of the token
+func (k *CacheKey) Download(tokenHash string) string {
+	return fmt.Sprintf("%s:v%d:download:token:%s", k.prefix, k.version, tokenHash)
+}
+
+// Idempotency is the upload result of the idempotency key, it is not a cache entry and is not matched by the owner pattern
+func (k *CacheKey) Idempotency(ownerID string, keyHash string) string {
+	return fmt.Sprintf("%s:v%d:idempotency:owner:%s:key:%s", k.prefix, k.version, ownerID, keyHash)
+}
+
+// IdempotencyLock is held while the upload of the idempotency key is in progress
+func (k *CacheKey) IdempotencyLock(ownerID string, keyHash string) string {
+	return fmt.Sprintf("%s:v%d:idempotency-lock:owner:%s:key:%s", k.prefix, k.version, ownerID, keyHash)
+}
+
+// Invalidation is the pub/sub channel of the local caches, it is shared by every version
+func (k *CacheKey) Invalidation() string {
+	return fmt.Sprintf("%s:invalidation", k.prefix)
+}
+
+// OwnerPattern matches the cached file of the owner across all versions, the lock is not matched so flushing the
+// cache never drops a lock held by a running load
+func (k *CacheKey) OwnerPattern(ownerID string) string {
+	return fmt.Sprintf("%s:*:file:owner:%s", globEscaper.Replace(k.prefix), globEscaper.Replace(ownerID))
+}
+
+// Pattern matches every entry of this service
+func (k *CacheKey) Pattern() string {
+	return fmt.Sprintf("%s:*", globEscaper.Replace(k.prefix))
+}
+
+func (k *CacheKey) build(kind string, ownerID string) string {
+	return fmt.Sprintf("%s:v%d:%s:owner:%s", k.prefix, k.version, kind, ownerID)
+}
@@ -19,6 +19,6 @@ func GetObjectName(filename string, secret string, fileType file.Type) (string,
 	case file.IMAGE:
 		return fmt.Sprintf("image-%s-%d-%s", filename, time.Now().Unix(), hashed), nil
 	default:
-		return "", nil
+		return "", fmt.Errorf("unknown file type %d", fileType)
 	}
 }
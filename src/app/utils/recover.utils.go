@@ -0,0 +1,26 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/isd-sgcu/rnkm65-file/src/metrics"
+	"github.com/rs/zerolog/log"
+	"runtime/debug"
+)
+
+// Recover logs the panic of a background job instead of taking the process down, it has to be deferred
+// by the goroutine of the job
+func Recover(job string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	metrics.JobPanics.WithLabelValues(job).Inc()
+
+	log.Error().
+		Str("module", "recovery").
+		Str("job", job).
+		Str("panic", fmt.Sprint(r)).
+		Bytes("stack", debug.Stack()).
+		Msg("Recovered from panic")
+}
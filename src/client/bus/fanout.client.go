@@ -0,0 +1,31 @@
+package bus
+
+import (
+	"context"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/event"
+)
+
+// Publisher is a bus the fanout publishes to
+type Publisher interface {
+	Publish(context.Context, *dto.Message) error
+}
+
+// FanoutClient publishes every event to each of the buses in order, a failure makes the relay publish the event
+// again to all of them so the buses have to tolerate the duplicates
+type FanoutClient struct {
+	buses []Publisher
+}
+
+func NewFanoutClient(buses ...Publisher) *FanoutClient {
+	return &FanoutClient{buses: buses}
+}
+
+func (c *FanoutClient) Publish(ctx context.Context, msg *dto.Message) error {
+	for _, bus := range c.buses {
+		if err := bus.Publish(ctx, msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
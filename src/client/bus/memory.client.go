@@ -0,0 +1,36 @@
+package bus
+
+import (
+	"context"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/event"
+	"sync"
+)
+
+// MemoryClient delivers the events to the handlers in this process, it is for the tests and the single replica setups
+type MemoryClient struct {
+	mu       sync.RWMutex
+	handlers []func(*dto.Message)
+}
+
+func NewMemoryClient() *MemoryClient {
+	return &MemoryClient{}
+}
+
+// Subscribe adds the handler, the handlers are called one after another on the relay goroutine
+func (c *MemoryClient) Subscribe(handler func(*dto.Message)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.handlers = append(c.handlers, handler)
+}
+
+func (c *MemoryClient) Publish(_ context.Context, msg *dto.Message) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, handler := range c.handlers {
+		handler(msg)
+	}
+
+	return nil
+}
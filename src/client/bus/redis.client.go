@@ -0,0 +1,45 @@
+package bus
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/event"
+	"time"
+)
+
+const defaultStream = "rnkm65-file:events"
+
+// RedisClient appends the events to a redis stream, the consumers read it with their own consumer group,
+// the stream is trimmed to about maxLen entries when maxLen is set
+type RedisClient struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+func NewRedisClient(client *redis.Client, stream string, maxLen int64) *RedisClient {
+	if stream == "" {
+		stream = defaultStream
+	}
+
+	return &RedisClient{
+		client: client,
+		stream: stream,
+		maxLen: maxLen,
+	}
+}
+
+func (c *RedisClient) Publish(ctx context.Context, msg *dto.Message) error {
+	return c.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: c.stream,
+		MaxLen: c.maxLen,
+		Approx: c.maxLen > 0,
+		Values: map[string]interface{}{
+			"id":          msg.ID,
+			"type":        msg.Type,
+			"key":         msg.Key,
+			"payload":     string(msg.Payload),
+			"occurred_at": msg.OccurredAt.Format(time.RFC3339Nano),
+		},
+	}).Err()
+}
@@ -4,44 +4,69 @@ import (
 	"bytes"
 	"cloud.google.com/go/storage"
 	"context"
+	"fmt"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
 	"github.com/isd-sgcu/rnkm65-file/src/config"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelCodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/api/option"
 	"io"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 )
 
+const publicBaseUrl = "https://storage.googleapis.com"
+
+var tracer = otel.Tracer("github.com/isd-sgcu/rnkm65-file/src/client/gcs")
+
 type Client struct {
-	conf config.GCS
+	conf   config.GCS
+	client *storage.Client
 }
 
-const SignUrlExpiresIn = 15
+// NewClient creates the storage client once, it is safe for concurrent use and has to be closed on shutdown
+func NewClient(conf config.GCS) (*Client, error) {
+	client, err := storage.NewClient(context.Background(), option.WithCredentialsJSON(conf.ServiceAccountJSON))
+	if err != nil {
+		return nil, errors.Wrap(err, "Cannot create google cloud storage client")
+	}
 
-func NewClient(conf config.GCS) *Client {
 	return &Client{
-		conf: conf,
-	}
+		conf:   conf,
+		client: client,
+	}, nil
+}
+
+func (c *Client) Close() error {
+	return c.client.Close()
+}
+
+func (c *Client) Upload(ctx context.Context, files []byte, filename string) error {
+	return c.upload(ctx, c.conf.BucketName, files, filename)
+}
+
+// UploadPublic uploads to the public bucket, the bucket or its public prefix has to be readable by allUsers
+func (c *Client) UploadPublic(ctx context.Context, files []byte, filename string) error {
+	return c.upload(ctx, c.publicBucket(), files, filename)
 }
 
-func (c *Client) Upload(files []byte, filename string) error {
-	ctx := context.Background()
+func (c *Client) upload(ctx context.Context, bucket string, files []byte, filename string) (err error) {
+	ctx, span := startSpan(ctx, "gcs.Upload", bucket, filename)
+	defer func() { endSpan(span, err) }()
+	span.SetAttributes(attribute.Int("gcs.size", len(files)))
+
 	ctx, cancel := context.WithTimeout(ctx, 50*time.Second)
 	defer cancel()
 
-	client, err := storage.NewClient(ctx, option.WithCredentialsJSON(c.conf.ServiceAccountJSON))
-	if err != nil {
-		log.Error().
-			Err(err).
-			Str("service", "file").
-			Str("module", "gcs client").
-			Msg("Cannot create google cloud storage client")
-	}
-	defer client.Close()
-
 	buf := bytes.NewBuffer(files)
 
-	wc := client.Bucket(c.conf.BucketName).Object(filename).NewWriter(ctx)
+	wc := c.client.Bucket(bucket).Object(filename).NewWriter(ctx)
 	wc.ChunkSize = 0
 
 	if _, err := io.Copy(wc, buf); err != nil {
@@ -52,7 +77,7 @@ func (c *Client) Upload(files []byte, filename string) error {
 		return errors.Wrap(err, "Error while closing the connection")
 	}
 	log.Info().
-		Str("bucket", c.conf.BucketName).
+		Str("bucket", bucket).
 		Str("service", "file").
 		Str("module", "gcs client").
 		Msgf("Successfully upload image %v", filename)
@@ -60,19 +85,198 @@ func (c *Client) Upload(files []byte, filename string) error {
 	return nil
 }
 
-func (c *Client) GetSignedUrl(filename string) (string, error) {
+func (c *Client) GetSignedUrl(ctx context.Context, filename string, expiresIn time.Duration) (_ string, err error) {
+	_, span := startSpan(ctx, "gcs.GetSignedUrl", c.conf.BucketName, filename)
+	defer func() { endSpan(span, err) }()
+
 	ops := storage.SignedURLOptions{
 		GoogleAccessID: c.conf.ServiceAccountEmail,
 		PrivateKey:     c.conf.ServiceAccountKey,
 		Method:         "GET",
-		Expires:        time.Now().Add(SignUrlExpiresIn * time.Minute),
+		Expires:        time.Now().Add(expiresIn),
 		Scheme:         storage.SigningSchemeV4,
 	}
 
-	url, err := storage.SignedURL(c.conf.BucketName, filename, &ops)
+	signedUrl, err := storage.SignedURL(c.conf.BucketName, filename, &ops)
 	if err != nil {
 		return "", err
 	}
 
-	return url, nil
+	return signedUrl, nil
+}
+
+// GetPublicUrl returns the permanent url of the public object, it needs no signing so the cdn can cache it
+func (c *Client) GetPublicUrl(filename string) string {
+	baseUrl := c.conf.Public.BaseUrl
+	if baseUrl == "" {
+		baseUrl = fmt.Sprintf("%s/%s", publicBaseUrl, c.publicBucket())
+	}
+
+	segments := strings.Split(filename, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(baseUrl, "/"), strings.Join(segments, "/"))
+}
+
+// Delete removes the object, a missing object is not an error
+func (c *Client) Delete(ctx context.Context, filename string, public bool) (err error) {
+	bucket := c.conf.BucketName
+	if public {
+		bucket = c.publicBucket()
+	}
+
+	ctx, span := startSpan(ctx, "gcs.Delete", bucket, filename)
+	defer func() { endSpan(span, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, 50*time.Second)
+	defer cancel()
+
+	err = c.client.Bucket(bucket).Object(filename).Delete(ctx)
+	if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+		return errors.Wrap(err, "Error while deleting the object")
+	}
+
+	return nil
+}
+
+// Open returns a seekable reader of the object pinned to its current generation, the ranges are read lazily
+// so only the requested bytes are downloaded, a missing object returns an error wrapping os.ErrNotExist
+func (c *Client) Open(ctx context.Context, filename string, public bool) (_ *dto.Object, err error) {
+	bucket := c.conf.BucketName
+	if public {
+		bucket = c.publicBucket()
+	}
+
+	obj := c.client.Bucket(bucket).Object(filename)
+
+	attrsCtx, span := startSpan(ctx, "gcs.Open", bucket, filename)
+	defer func() { endSpan(span, err) }()
+
+	attrs, err := obj.Attrs(attrsCtx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, fmt.Errorf("%s: %w", filename, os.ErrNotExist)
+		}
+		return nil, errors.Wrap(err, "Error while reading the object attributes")
+	}
+
+	return &dto.Object{
+		ReadSeekCloser: &objectReader{
+			ctx:  ctx,
+			obj:  obj.Generation(attrs.Generation),
+			size: attrs.Size,
+		},
+		ContentType: attrs.ContentType,
+		ETag:        attrs.Etag,
+		Size:        attrs.Size,
+		UpdatedAt:   attrs.Updated,
+	}, nil
+}
+
+// Ping reads the attributes of the buckets to check the credentials and the connection
+func (c *Client) Ping(ctx context.Context) error {
+	buckets := []string{c.conf.BucketName}
+	if public := c.publicBucket(); public != c.conf.BucketName {
+		buckets = append(buckets, public)
+	}
+
+	for _, bucket := range buckets {
+		if _, err := c.client.Bucket(bucket).Attrs(ctx); err != nil {
+			return errors.Wrapf(err, "Cannot read the attributes of bucket %v", bucket)
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) publicBucket() string {
+	if c.conf.Public.Bucket != "" {
+		return c.conf.Public.Bucket
+	}
+
+	return c.conf.BucketName
+}
+
+// objectReader opens a range reader from the current offset on the first read after a seek
+type objectReader struct {
+	ctx    context.Context
+	obj    *storage.ObjectHandle
+	size   int64
+	offset int64
+	reader *storage.Reader
+}
+
+func (r *objectReader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.reader == nil {
+		reader, err := r.obj.NewRangeReader(r.ctx, r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.reader = reader
+	}
+
+	n, err := r.reader.Read(p)
+	r.offset += int64(n)
+
+	return n, err
+}
+
+func (r *objectReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.offset + offset
+	case io.SeekEnd:
+		abs = r.size + offset
+	default:
+		return 0, errors.New("Invalid whence")
+	}
+
+	if abs < 0 {
+		return 0, errors.New("Negative position")
+	}
+
+	if abs != r.offset {
+		if err := r.Close(); err != nil {
+			return 0, err
+		}
+		r.offset = abs
+	}
+
+	return abs, nil
+}
+
+func (r *objectReader) Close() error {
+	if r.reader == nil {
+		return nil
+	}
+
+	err := r.reader.Close()
+	r.reader = nil
+
+	return err
+}
+
+func startSpan(ctx context.Context, name string, bucket string, filename string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("gcs.bucket", bucket),
+		attribute.String("gcs.object", filename),
+	))
+}
+
+// endSpan records the error on the span, a missing object is an expected outcome and is not marked as an error
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		span.RecordError(err)
+		span.SetStatus(otelCodes.Error, err.Error())
+	}
+	span.End()
 }
@@ -0,0 +1,85 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
+	"github.com/isd-sgcu/rnkm65-file/src/metrics"
+	"os"
+	"time"
+)
+
+type storageClient interface {
+	Upload(context.Context, []byte, string) error
+	UploadPublic(context.Context, []byte, string) error
+	GetSignedUrl(context.Context, string, time.Duration) (string, error)
+	GetPublicUrl(string) string
+	Delete(context.Context, string, bool) error
+	Open(context.Context, string, bool) (*dto.Object, error)
+}
+
+// MetricsClient observes the latency of the calls to the wrapped client by operation and result,
+// the public url is built locally so it is not timed
+type MetricsClient struct {
+	client storageClient
+}
+
+func NewMetricsClient(client storageClient) *MetricsClient {
+	return &MetricsClient{client: client}
+}
+
+func (c *MetricsClient) Upload(ctx context.Context, files []byte, filename string) error {
+	start := time.Now()
+	err := c.client.Upload(ctx, files, filename)
+	observe("upload", start, err)
+
+	return err
+}
+
+func (c *MetricsClient) UploadPublic(ctx context.Context, files []byte, filename string) error {
+	start := time.Now()
+	err := c.client.UploadPublic(ctx, files, filename)
+	observe("upload_public", start, err)
+
+	return err
+}
+
+func (c *MetricsClient) GetSignedUrl(ctx context.Context, filename string, expiresIn time.Duration) (string, error) {
+	start := time.Now()
+	url, err := c.client.GetSignedUrl(ctx, filename, expiresIn)
+	observe("get_signed_url", start, err)
+
+	return url, err
+}
+
+func (c *MetricsClient) GetPublicUrl(filename string) string {
+	return c.client.GetPublicUrl(filename)
+}
+
+func (c *MetricsClient) Delete(ctx context.Context, filename string, public bool) error {
+	start := time.Now()
+	err := c.client.Delete(ctx, filename, public)
+	observe("delete", start, err)
+
+	return err
+}
+
+// Open only times the attributes lookup, the ranges are read while the response is written
+func (c *MetricsClient) Open(ctx context.Context, filename string, public bool) (*dto.Object, error) {
+	start := time.Now()
+	obj, err := c.client.Open(ctx, filename, public)
+	observe("open", start, err)
+
+	return obj, err
+}
+
+func observe(operation string, start time.Time, err error) {
+	result := "ok"
+	if errors.Is(err, os.ErrNotExist) {
+		result = "not_found"
+	} else if err != nil {
+		result = "error"
+	}
+
+	metrics.StorageDuration.WithLabelValues(operation, result).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,52 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const maxResponseSize = 4 * 1024
+
+type Client struct {
+	client *http.Client
+}
+
+// NewClient posts the webhooks, the redirects are not followed so a payload is never sent to where the subscriber did not ask
+func NewClient(timeout time.Duration) *Client {
+	return &Client{
+		client: &http.Client{
+			Timeout: timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Post returns the status code of the response, the error is only for the requests that got no response
+func (c *Client) Post(ctx context.Context, url string, header http.Header, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, errors.Wrap(err, "Invalid webhook request")
+	}
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	// drain a little of the body so the connection can be reused
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(res.Body, maxResponseSize))
+
+	return res.StatusCode, nil
+}
@@ -7,13 +7,105 @@ import (
 )
 
 type GCS struct {
-	BucketName          string `mapstructure:"bucket_name"`
-	Secret              string `mapstructure:"image_secret"`
-	ServiceAccountEmail string `mapstructure:"service_account_email"`
+	BucketName          string    `mapstructure:"bucket_name"`
+	Secret              string    `mapstructure:"image_secret"`
+	ServiceAccountEmail string    `mapstructure:"service_account_email"`
+	UrlExpiry           UrlExpiry `mapstructure:"url_expiry"`
+	Public              Public    `mapstructure:"public"`
+	Proxy               Proxy     `mapstructure:"proxy"`
+	Tags                Tags      `mapstructure:"tags"`
 	ServiceAccountKey   []byte
 	ServiceAccountJSON  []byte
 }
 
+// Tags is the registry of the file tags by id, the rpcs reject the tags that are not registered, an empty registry
+// accepts any tag and zero is always accepted as an untagged file
+type Tags map[int]string
+
+// UrlExpiry holds the signed url lifetime in seconds, the tag override wins over the type override
+type UrlExpiry struct {
+	Default      int            `mapstructure:"default"`
+	SafetyMargin int            `mapstructure:"safety_margin"`
+	Types        map[string]int `mapstructure:"types"`
+	Tags         map[int]int    `mapstructure:"tags"`
+}
+
+// Public is where the public files go, the bucket defaults to the private one and the base url
+// defaults to the google cloud storage url of the bucket, the files of the tags are always public
+type Public struct {
+	Bucket  string `mapstructure:"bucket"`
+	Prefix  string `mapstructure:"prefix"`
+	BaseUrl string `mapstructure:"base_url"`
+	Tags    []int  `mapstructure:"tags"`
+}
+
+// Proxy serves the private files through the download proxy instead of the signed urls when enabled,
+// the token ttl is in seconds and the base url is prepended to the token
+type Proxy struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	BaseUrl  string `mapstructure:"base_url"`
+	TokenTTL int    `mapstructure:"token_ttl"`
+}
+
+// Outbox relays the file events to the bus, the bus is memory or redis and the stream is the redis stream key,
+// the poll interval is in milliseconds, the retention of the published events and the claim ttl of a batch are in seconds
+type Outbox struct {
+	Enabled      bool   `mapstructure:"enabled"`
+	Bus          string `mapstructure:"bus"`
+	Stream       string `mapstructure:"stream"`
+	MaxLen       int64  `mapstructure:"max_len"`
+	PollInterval int    `mapstructure:"poll_interval"`
+	BatchSize    int    `mapstructure:"batch_size"`
+	Retention    int    `mapstructure:"retention"`
+	ClaimTTL     int    `mapstructure:"claim_ttl"`
+}
+
+// Webhook posts the file events relayed from the outbox, the backoff doubles from Backoff up to MaxBackoff seconds
+// between the attempts, the timeout is in seconds and the poll interval is in milliseconds
+type Webhook struct {
+	Enabled       bool                  `mapstructure:"enabled"`
+	Subscriptions []WebhookSubscription `mapstructure:"subscriptions"`
+	MaxAttempts   int                   `mapstructure:"max_attempts"`
+	Backoff       int                   `mapstructure:"backoff"`
+	MaxBackoff    int                   `mapstructure:"max_backoff"`
+	Timeout       int                   `mapstructure:"timeout"`
+	PollInterval  int                   `mapstructure:"poll_interval"`
+	BatchSize     int                   `mapstructure:"batch_size"`
+}
+
+// WebhookSubscription is a subscription from the config, an empty Events subscribes to every event
+type WebhookSubscription struct {
+	Name   string   `mapstructure:"name"`
+	Url    string   `mapstructure:"url"`
+	Secret string   `mapstructure:"secret"`
+	Events []string `mapstructure:"events"`
+}
+
+// Audit queues the audit entries and inserts them in batches, the flush interval is in milliseconds
+// and a full buffer logs the entries instead
+type Audit struct {
+	BufferSize    int `mapstructure:"buffer_size"`
+	BatchSize     int `mapstructure:"batch_size"`
+	FlushInterval int `mapstructure:"flush_interval"`
+}
+
+// Tracing exports the spans with the exporter, it is otlp, stdout or none, the endpoint is the otlp grpc collector
+// and the sample ratio applies to the traces started here, zero samples every trace and the incoming
+// traces keep the sampling of the caller
+type Tracing struct {
+	Exporter    string  `mapstructure:"exporter"`
+	Endpoint    string  `mapstructure:"endpoint"`
+	Insecure    bool    `mapstructure:"insecure"`
+	ServiceName string  `mapstructure:"service_name"`
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+}
+
+// Health probes the dependencies every interval, the interval and the timeout of a probe are in seconds
+type Health struct {
+	Interval int `mapstructure:"interval"`
+	Timeout  int `mapstructure:"timeout"`
+}
+
 type Redis struct {
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
@@ -29,16 +121,73 @@ type Database struct {
 	SSL      string `mapstructure:"ssl"`
 }
 
+// App holds the server settings, the shutdown timeout is the deadline of the graceful shutdown in seconds
 type App struct {
-	Port        int  `mapstructure:"port"`
-	Debug       bool `mapstructure:"debug"`
-	CacheTTL    int  `mapstructure:"cache_ttl"`
-	MaxFileSize int  `mapstructure:"max_file_size"`
+	Port            int  `mapstructure:"port"`
+	HttpPort        int  `mapstructure:"http_port"`
+	Debug           bool `mapstructure:"debug"`
+	CacheTTL        int  `mapstructure:"cache_ttl"`
+	MaxFileSize     int  `mapstructure:"max_file_size"`
+	ShutdownTimeout int  `mapstructure:"shutdown_timeout"`
+}
+
+// Cache holds the redis settings in seconds, the idempotency ttl is how long an upload result is kept
+// for the retries with the same idempotency key and zero turns the idempotency keys off
+type Cache struct {
+	Prefix             string `mapstructure:"prefix"`
+	Version            int    `mapstructure:"version"`
+	LockTTL            int    `mapstructure:"lock_ttl"`
+	NegativeTTL        int    `mapstructure:"negative_ttl"`
+	RefreshWindow      int    `mapstructure:"refresh_window"`
+	WarmSize           int    `mapstructure:"warm_size"`
+	LocalSize          int    `mapstructure:"local_size"`
+	LocalTTL           int    `mapstructure:"local_ttl"`
+	IdempotencyTTL     int    `mapstructure:"idempotency_ttl"`
+	IdempotencyLockTTL int    `mapstructure:"idempotency_lock_ttl"`
+}
+
+type ServiceAuth struct {
+	Name        string   `mapstructure:"name"`
+	ApiKey      string   `mapstructure:"api_key"`
+	Permissions []string `mapstructure:"permissions"`
+	Trusted     bool     `mapstructure:"trusted"`
+}
+
+// Jwt verifies the end user token with the shared secret or the keys from the jwks url,
+// the refresh interval is in seconds
+type Jwt struct {
+	Secret              string `mapstructure:"secret"`
+	JwksUrl             string `mapstructure:"jwks_url"`
+	JwksRefreshInterval int    `mapstructure:"jwks_refresh_interval"`
+	Issuer              string `mapstructure:"issuer"`
+	Audience            string `mapstructure:"audience"`
+}
+
+type Auth struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	AdminRole string        `mapstructure:"admin_role"`
+	Jwt       Jwt           `mapstructure:"jwt"`
+	Services  []ServiceAuth `mapstructure:"services"`
+}
+
+// Link holds the share link settings in seconds, the base url is prepended to the token
+type Link struct {
+	BaseUrl    string `mapstructure:"base_url"`
+	DefaultTTL int    `mapstructure:"default_ttl"`
+	UrlExpiry  int    `mapstructure:"url_expiry"`
 }
 
 type Config struct {
 	GCS      GCS      `mapstructure:"gcs"`
 	App      App      `mapstructure:"app"`
+	Auth     Auth     `mapstructure:"auth"`
+	Cache    Cache    `mapstructure:"cache"`
+	Link     Link     `mapstructure:"link"`
+	Outbox   Outbox   `mapstructure:"outbox"`
+	Webhook  Webhook  `mapstructure:"webhook"`
+	Audit    Audit    `mapstructure:"audit"`
+	Tracing  Tracing  `mapstructure:"tracing"`
+	Health   Health   `mapstructure:"health"`
 	Database Database `mapstructure:"database"`
 	Redis    Redis    `mapstructure:"redis"`
 }
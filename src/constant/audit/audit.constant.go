@@ -0,0 +1,16 @@
+package audit
+
+type Action string
+
+const (
+	UPLOAD                Action = "upload"
+	GET_SIGNED_URL        Action = "get_signed_url"
+	BATCH_GET_SIGNED_URLS Action = "batch_get_signed_urls"
+	DELETE                Action = "delete"
+	SHARE_FILE            Action = "share_file"
+	REVOKE_SHARE          Action = "revoke_share"
+	CREATE_SHARE_LINK     Action = "create_share_link"
+	REVOKE_SHARE_LINK     Action = "revoke_share_link"
+	RESOLVE_SHARE_LINK    Action = "resolve_share_link"
+	OPEN_DOWNLOAD         Action = "open_download"
+)
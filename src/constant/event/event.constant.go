@@ -0,0 +1,9 @@
+package event
+
+type Type string
+
+const (
+	FILE_UPLOADED Type = "FileUploaded"
+	FILE_REPLACED Type = "FileReplaced"
+	FILE_DELETED  Type = "FileDeleted"
+)
@@ -4,5 +4,16 @@ type Type int
 
 const (
 	FILE  Type = 1
-	IMAGE      = 2
+	IMAGE Type = 2
 )
+
+func (t Type) String() string {
+	switch t {
+	case FILE:
+		return "file"
+	case IMAGE:
+		return "image"
+	default:
+		return ""
+	}
+}
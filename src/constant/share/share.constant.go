@@ -0,0 +1,8 @@
+package share
+
+type GranteeType string
+
+const (
+	USER  GranteeType = "user"
+	GROUP GranteeType = "group"
+)
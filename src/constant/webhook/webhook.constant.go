@@ -0,0 +1,9 @@
+package webhook
+
+type Status string
+
+const (
+	PENDING   Status = "pending"
+	DELIVERED Status = "delivered"
+	DEAD      Status = "dead"
+)
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"errors"
+	"github.com/isd-sgcu/rnkm65-file/src/metrics"
+	"gorm.io/gorm"
+	"time"
+)
+
+const startedAtKey = "metrics:started_at"
+
+// MetricsPlugin observes the latency of every statement through the gorm callbacks
+type MetricsPlugin struct{}
+
+func (p *MetricsPlugin) Name() string {
+	return "metrics"
+}
+
+func (p *MetricsPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("metrics:before_create", start),
+		cb.Create().After("gorm:create").Register("metrics:after_create", observe("create")),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", start),
+		cb.Query().After("gorm:query").Register("metrics:after_query", observe("query")),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", start),
+		cb.Update().After("gorm:update").Register("metrics:after_update", observe("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", start),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", observe("delete")),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", start),
+		cb.Row().After("gorm:row").Register("metrics:after_row", observe("row")),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", start),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", observe("raw")),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func start(db *gorm.DB) {
+	db.InstanceSet(startedAtKey, time.Now())
+}
+
+func observe(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(startedAtKey)
+		if !ok {
+			return
+		}
+
+		result := "ok"
+		if errors.Is(db.Error, gorm.ErrRecordNotFound) {
+			result = "not_found"
+		} else if db.Error != nil {
+			result = "error"
+		}
+
+		metrics.DatabaseDuration.
+			WithLabelValues(operation, db.Statement.Table, result).
+			Observe(time.Since(v.(time.Time)).Seconds())
+	}
+}
@@ -2,13 +2,21 @@ package database
 
 import (
 	"fmt"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/audit"
 	"github.com/isd-sgcu/rnkm65-file/src/app/model/file"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/link"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/outbox"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/share"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/webhook"
 	"github.com/isd-sgcu/rnkm65-file/src/config"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"strconv"
 )
 
+// models are the tables migrated on start
+var models = []interface{}{file.File{}, share.Share{}, link.Link{}, audit.Audit{}, outbox.Event{}, webhook.Subscription{}, webhook.Delivery{}}
+
 func InitDatabase(conf *config.Database) (db *gorm.DB, err error) {
 	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8&parseTime=True", conf.User, conf.Password, conf.Host, strconv.Itoa(conf.Port), conf.Name)
 
@@ -17,7 +25,17 @@ func InitDatabase(conf *config.Database) (db *gorm.DB, err error) {
 		return nil, err
 	}
 
-	err = db.AutoMigrate(file.File{})
+	err = db.Use(&MetricsPlugin{})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Use(&TracingPlugin{})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.AutoMigrate(models...)
 	if err != nil {
 		return nil, err
 	}
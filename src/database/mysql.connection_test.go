@@ -0,0 +1,40 @@
+package database
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm/schema"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type MigrationTest struct {
+	suite.Suite
+}
+
+func TestMigration(t *testing.T) {
+	suite.Run(t, new(MigrationTest))
+}
+
+// TestIndexedColumnsHaveLength checks the column types mysql creates for the indexes, it refuses a key on a text column
+func (t *MigrationTest) TestIndexedColumnsHaveLength() {
+	dialector := mysql.Dialector{Config: &mysql.Config{}}
+
+	for _, m := range models {
+		s, err := schema.Parse(m, &sync.Map{}, schema.NamingStrategy{})
+		assert.NoError(t.T(), err)
+
+		for _, idx := range s.ParseIndexes() {
+			for _, f := range idx.Fields {
+				if f.DataType != schema.String {
+					continue
+				}
+
+				dataType := dialector.DataTypeOf(f.Field)
+				assert.False(t.T(), strings.HasSuffix(dataType, "text"), "%v.%v of %v is %v", s.Table, f.DBName, idx.Name, dataType)
+			}
+		}
+	}
+}
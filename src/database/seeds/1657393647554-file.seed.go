@@ -11,6 +11,7 @@ func (s Seed) UserSeed1655751437484() error {
 			Filename: faker.Word(),
 			OwnerID:  faker.UUIDDigit(),
 			Tag:      1,
+			Type:     1,
 		}
 		err := s.db.Create(&usr).Error
 
@@ -0,0 +1,153 @@
+package interceptor
+
+import (
+	"context"
+	"crypto/subtle"
+	"github.com/isd-sgcu/rnkm65-file/src/app/auth"
+	"github.com/isd-sgcu/rnkm65-file/src/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+)
+
+const (
+	ApiKeyHeader     = "x-api-key"
+	UserIDHeader     = "x-user-id"
+	UserRolesHeader  = "x-user-roles"
+	UserGroupsHeader = "x-user-groups"
+)
+
+var publicMethodPrefixes = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.",
+}
+
+type AuthInterceptor struct {
+	conf config.Auth
+}
+
+func NewAuthInterceptor(conf config.Auth) *AuthInterceptor {
+	return &AuthInterceptor{conf: conf}
+}
+
+func (i *AuthInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := i.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func (i *AuthInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := i.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// authenticate resolves the calling service from its api key, the end user headers are only taken from a trusted service
+func (i *AuthInterceptor) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if !i.conf.Enabled || isPublicMethod(method) {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	apiKey := firstValue(md, ApiKeyHeader)
+	if apiKey == "" {
+		return nil, status.Error(codes.Unauthenticated, "Missing api key")
+	}
+
+	service, ok := i.findService(apiKey)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "Invalid api key")
+	}
+
+	caller := &auth.Caller{
+		Service: service.Name,
+		Trusted: service.Trusted,
+	}
+
+	for _, p := range service.Permissions {
+		caller.Permissions = append(caller.Permissions, auth.Permission(p))
+	}
+
+	if service.Trusted {
+		caller.Subject = firstValue(md, UserIDHeader)
+		setRoles(caller, splitList(firstValue(md, UserRolesHeader)), i.conf.AdminRole)
+		caller.Groups = splitList(firstValue(md, UserGroupsHeader))
+	}
+
+	return auth.NewContext(ctx, caller), nil
+}
+
+func (i *AuthInterceptor) findService(apiKey string) (*config.ServiceAuth, bool) {
+	var found *config.ServiceAuth
+	for idx := range i.conf.Services {
+		service := &i.conf.Services[idx]
+		if service.ApiKey != "" && subtle.ConstantTimeCompare([]byte(service.ApiKey), []byte(apiKey)) == 1 {
+			found = service
+		}
+	}
+
+	return found, found != nil
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func setRoles(caller *auth.Caller, roles []string, adminRole string) {
+	caller.Roles = nil
+	caller.IsAdmin = false
+
+	for _, role := range roles {
+		caller.Roles = append(caller.Roles, role)
+		if adminRole != "" && role == adminRole {
+			caller.IsAdmin = true
+		}
+	}
+}
+
+func splitList(value string) []string {
+	var result []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
+
+func isPublicMethod(method string) bool {
+	for _, prefix := range publicMethodPrefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+
+	return ""
+}
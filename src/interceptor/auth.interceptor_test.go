@@ -0,0 +1,113 @@
+package interceptor
+
+import (
+	"context"
+	"github.com/isd-sgcu/rnkm65-file/src/app/auth"
+	"github.com/isd-sgcu/rnkm65-file/src/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+type AuthInterceptorTest struct {
+	suite.Suite
+	conf   config.Auth
+	info   *grpc.UnaryServerInfo
+	caller *auth.Caller
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	suite.Run(t, new(AuthInterceptorTest))
+}
+
+func (t *AuthInterceptorTest) SetupTest() {
+	t.conf = config.Auth{
+		Enabled:   true,
+		AdminRole: "admin",
+		Services: []config.ServiceAuth{
+			{Name: "gateway", ApiKey: "gateway-key", Trusted: true},
+			{Name: "checkin", ApiKey: "checkin-key", Permissions: []string{"read"}},
+		},
+	}
+
+	t.info = &grpc.UnaryServerInfo{FullMethod: "/file.FileService/GetSignedUrl"}
+	t.caller = nil
+}
+
+func (t *AuthInterceptorTest) handler(ctx context.Context, _ interface{}) (interface{}, error) {
+	t.caller, _ = auth.FromContext(ctx)
+	return "ok", nil
+}
+
+func (t *AuthInterceptorTest) call(md metadata.MD) (interface{}, error) {
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	return NewAuthInterceptor(t.conf).Unary()(ctx, nil, t.info, t.handler)
+}
+
+func (t *AuthInterceptorTest) TestMissingApiKey() {
+	_, err := t.call(metadata.MD{})
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), codes.Unauthenticated, st.Code())
+	assert.Nil(t.T(), t.caller)
+}
+
+func (t *AuthInterceptorTest) TestInvalidApiKey() {
+	_, err := t.call(metadata.Pairs(ApiKeyHeader, "wrong-key"))
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), codes.Unauthenticated, st.Code())
+}
+
+func (t *AuthInterceptorTest) TestResolveCaller() {
+	_, err := t.call(metadata.Pairs(ApiKeyHeader, "gateway-key", UserIDHeader, "user-1", UserRolesHeader, "staff, admin"))
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), &auth.Caller{
+		Service: "gateway",
+		Trusted: true,
+		Subject: "user-1",
+		Roles:   []string{"staff", "admin"},
+		IsAdmin: true,
+	}, t.caller)
+}
+
+func (t *AuthInterceptorTest) TestUntrustedUserHeadersIgnored() {
+	_, err := t.call(metadata.Pairs(ApiKeyHeader, "checkin-key", UserIDHeader, "user-1", UserRolesHeader, "admin"))
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), "", t.caller.Subject)
+	assert.False(t.T(), t.caller.IsAdmin)
+}
+
+func (t *AuthInterceptorTest) TestServicePermissions() {
+	_, err := t.call(metadata.Pairs(ApiKeyHeader, "checkin-key"))
+
+	assert.Nil(t.T(), err)
+	assert.True(t.T(), t.caller.Can(auth.Read))
+	assert.False(t.T(), t.caller.Can(auth.Write))
+}
+
+func (t *AuthInterceptorTest) TestHealthCheckIsPublic() {
+	t.info = &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+
+	_, err := t.call(metadata.MD{})
+
+	assert.Nil(t.T(), err)
+}
+
+func (t *AuthInterceptorTest) TestDisabled() {
+	t.conf.Enabled = false
+
+	_, err := t.call(metadata.MD{})
+
+	assert.Nil(t.T(), err)
+}
@@ -0,0 +1,179 @@
+package interceptor
+
+import (
+	"context"
+	"github.com/MicahParks/keyfunc"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/isd-sgcu/rnkm65-file/src/app/auth"
+	"github.com/isd-sgcu/rnkm65-file/src/config"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+	"time"
+)
+
+const AuthorizationHeader = "authorization"
+
+var (
+	hmacMethods = []string{"HS256", "HS384", "HS512"}
+	jwksMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+)
+
+type Claims struct {
+	Roles  []string `json:"roles"`
+	Groups []string `json:"groups"`
+	jwt.RegisteredClaims
+}
+
+// JWTInterceptor verifies the end user token and puts its subject into the caller,
+// it runs after the AuthInterceptor so the token subject wins over the user id header
+type JWTInterceptor struct {
+	conf    config.Auth
+	jwks    *keyfunc.JWKS
+	methods []string
+}
+
+func NewJWTInterceptor(conf config.Auth) (*JWTInterceptor, error) {
+	i := &JWTInterceptor{conf: conf}
+
+	if conf.Jwt.Secret != "" {
+		i.methods = append(i.methods, hmacMethods...)
+	}
+
+	if conf.Jwt.JwksUrl != "" {
+		jwks, err := keyfunc.Get(conf.Jwt.JwksUrl, keyfunc.Options{
+			RefreshInterval:   time.Duration(conf.Jwt.JwksRefreshInterval) * time.Second,
+			RefreshRateLimit:  time.Minute,
+			RefreshUnknownKID: true,
+			RefreshErrorHandler: func(err error) {
+				log.Error().
+					Err(err).
+					Str("module", "jwt").
+					Str("jwks_url", conf.Jwt.JwksUrl).
+					Msg("Cannot refresh the jwks")
+			},
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "error occurs while getting the jwks")
+		}
+
+		i.jwks = jwks
+		i.methods = append(i.methods, jwksMethods...)
+	}
+
+	return i, nil
+}
+
+func (i *JWTInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := i.authenticate(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func (i *JWTInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := i.authenticate(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// Close stops the background jwks refresh
+func (i *JWTInterceptor) Close() {
+	if i.jwks != nil {
+		i.jwks.EndBackground()
+	}
+}
+
+// authenticate leaves the context untouched when there is no token, the service decides whether the call needs an end user
+func (i *JWTInterceptor) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if !i.conf.Enabled || len(i.methods) == 0 || isPublicMethod(method) {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	token := firstValue(md, AuthorizationHeader)
+	if token == "" {
+		return ctx, nil
+	}
+
+	if len(token) > 7 && strings.EqualFold(token[:7], "bearer ") {
+		token = token[7:]
+	}
+
+	claims, err := i.verify(token)
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("module", "jwt").
+			Str("method", method).
+			Msg("Invalid token")
+		return nil, status.Error(codes.Unauthenticated, "Invalid token")
+	}
+
+	caller := &auth.Caller{}
+	if c, ok := auth.FromContext(ctx); ok {
+		*caller = *c
+	}
+
+	caller.Subject = claims.Subject
+	setRoles(caller, claims.Roles, i.conf.AdminRole)
+	caller.Groups = claims.Groups
+
+	return auth.NewContext(ctx, caller), nil
+}
+
+func (i *JWTInterceptor) verify(token string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, i.keyfunc, jwt.WithValidMethods(i.methods))
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Subject == "" {
+		return nil, errors.New("missing subject")
+	}
+
+	if claims.ExpiresAt == nil {
+		return nil, errors.New("missing expiry")
+	}
+
+	if i.conf.Jwt.Issuer != "" && !claims.VerifyIssuer(i.conf.Jwt.Issuer, true) {
+		return nil, errors.New("invalid issuer")
+	}
+
+	if i.conf.Jwt.Audience != "" && !claims.VerifyAudience(i.conf.Jwt.Audience, true) {
+		return nil, errors.New("invalid audience")
+	}
+
+	return claims, nil
+}
+
+func (i *JWTInterceptor) keyfunc(token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if i.conf.Jwt.Secret == "" {
+			return nil, errors.New("hmac token is not accepted")
+		}
+
+		return []byte(i.conf.Jwt.Secret), nil
+	}
+
+	if i.jwks == nil {
+		return nil, errors.New("jwks is not configured")
+	}
+
+	return i.jwks.Keyfunc(token)
+}
@@ -0,0 +1,183 @@
+package interceptor
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/isd-sgcu/rnkm65-file/src/app/auth"
+	"github.com/isd-sgcu/rnkm65-file/src/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type JWTInterceptorTest struct {
+	suite.Suite
+	conf   config.Auth
+	info   *grpc.UnaryServerInfo
+	claims *Claims
+	caller *auth.Caller
+}
+
+func TestJWTInterceptor(t *testing.T) {
+	suite.Run(t, new(JWTInterceptorTest))
+}
+
+func (t *JWTInterceptorTest) SetupTest() {
+	t.conf = config.Auth{
+		Enabled:   true,
+		AdminRole: "admin",
+		Jwt: config.Jwt{
+			Secret: "secret",
+			Issuer: "rnkm65-auth",
+		},
+	}
+
+	t.info = &grpc.UnaryServerInfo{FullMethod: "/file.FileService/GetSignedUrl"}
+
+	t.claims = &Claims{
+		Roles: []string{"admin"},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "user-1",
+			Issuer:    "rnkm65-auth",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+
+	t.caller = nil
+}
+
+func (t *JWTInterceptorTest) handler(ctx context.Context, _ interface{}) (interface{}, error) {
+	t.caller, _ = auth.FromContext(ctx)
+	return "ok", nil
+}
+
+func (t *JWTInterceptorTest) sign(key interface{}, method jwt.SigningMethod) string {
+	token, err := jwt.NewWithClaims(method, t.claims).SignedString(key)
+	assert.Nil(t.T(), err)
+
+	return token
+}
+
+func (t *JWTInterceptorTest) call(ctx context.Context, token string) error {
+	i, err := NewJWTInterceptor(t.conf)
+	assert.Nil(t.T(), err)
+	defer i.Close()
+
+	if token != "" {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(AuthorizationHeader, "Bearer "+token))
+	}
+
+	_, err = i.Unary()(ctx, nil, t.info, t.handler)
+
+	return err
+}
+
+func (t *JWTInterceptorTest) TestSubjectFromToken() {
+	ctx := auth.NewContext(context.Background(), &auth.Caller{Service: "gateway", Subject: "user-2"})
+
+	err := t.call(ctx, t.sign([]byte("secret"), jwt.SigningMethodHS256))
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), &auth.Caller{
+		Service: "gateway",
+		Subject: "user-1",
+		Roles:   []string{"admin"},
+		IsAdmin: true,
+	}, t.caller)
+}
+
+func (t *JWTInterceptorTest) TestNoToken() {
+	err := t.call(context.Background(), "")
+
+	assert.Nil(t.T(), err)
+	assert.Nil(t.T(), t.caller)
+}
+
+func (t *JWTInterceptorTest) TestInvalidSignature() {
+	err := t.call(context.Background(), t.sign([]byte("wrong"), jwt.SigningMethodHS256))
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), codes.Unauthenticated, st.Code())
+}
+
+func (t *JWTInterceptorTest) TestExpired() {
+	t.claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+
+	err := t.call(context.Background(), t.sign([]byte("secret"), jwt.SigningMethodHS256))
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), codes.Unauthenticated, st.Code())
+}
+
+func (t *JWTInterceptorTest) TestMissingExpiry() {
+	t.claims.ExpiresAt = nil
+
+	err := t.call(context.Background(), t.sign([]byte("secret"), jwt.SigningMethodHS256))
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), codes.Unauthenticated, st.Code())
+}
+
+func (t *JWTInterceptorTest) TestInvalidIssuer() {
+	t.claims.Issuer = "someone-else"
+
+	err := t.call(context.Background(), t.sign([]byte("secret"), jwt.SigningMethodHS256))
+
+	st, ok := status.FromError(err)
+
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), codes.Unauthenticated, st.Code())
+}
+
+func (t *JWTInterceptorTest) TestJwks() {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.Nil(t.T(), err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"keys":[{"kty":"RSA","kid":"key-1","alg":"RS256","n":"%s","e":"%s"}]}`,
+			base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()))
+	}))
+	defer server.Close()
+
+	t.conf.Jwt.Secret = ""
+	t.conf.Jwt.JwksUrl = server.URL
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, t.claims)
+	token.Header["kid"] = "key-1"
+	signed, err := token.SignedString(key)
+	assert.Nil(t.T(), err)
+
+	err = t.call(context.Background(), signed)
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), "user-1", t.caller.Subject)
+}
+
+func (t *JWTInterceptorTest) TestHmacRejectedWithoutSecret() {
+	t.conf.Jwt.Secret = ""
+	t.conf.Jwt.JwksUrl = ""
+
+	err := t.call(context.Background(), t.sign([]byte("secret"), jwt.SigningMethodHS256))
+
+	assert.Nil(t.T(), err)
+	assert.Nil(t.T(), t.caller)
+}
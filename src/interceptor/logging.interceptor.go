@@ -0,0 +1,151 @@
+package interceptor
+
+import (
+	"context"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"time"
+)
+
+const (
+	RequestIDHeader    = "x-request-id"
+	maxRequestIDLength = 128
+)
+
+// LoggingInterceptor takes the request id from the caller or assigns one, attaches a logger with the request id
+// to the context for log.Ctx and writes one access line per call, the request id is sent back in the header
+type LoggingInterceptor struct {
+	now func() time.Time
+}
+
+func NewLoggingInterceptor() *LoggingInterceptor {
+	return &LoggingInterceptor{now: time.Now}
+}
+
+func (i *LoggingInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := i.now()
+		ctx = i.withLogger(ctx, info.FullMethod)
+
+		res, err := handler(ctx, req)
+
+		i.access(ctx, start, err, messageSize(req), messageSize(res))
+
+		return res, err
+	}
+}
+
+func (i *LoggingInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := i.now()
+		stream := &sizedServerStream{ServerStream: ss, ctx: i.withLogger(ss.Context(), info.FullMethod)}
+
+		err := handler(srv, stream)
+
+		i.access(stream.ctx, start, err, stream.received, stream.sent)
+
+		return err
+	}
+}
+
+func (i *LoggingInterceptor) withLogger(ctx context.Context, method string) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	requestId := firstValue(md, RequestIDHeader)
+	if !validRequestID(requestId) {
+		requestId = uuid.New().String()
+	}
+
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, requestId))
+
+	logger := log.With().
+		Str("request_id", requestId).
+		Str("method", method)
+
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		logger = logger.Str("trace_id", sc.TraceID().String())
+	}
+
+	return logger.Logger().WithContext(ctx)
+}
+
+func (i *LoggingInterceptor) access(ctx context.Context, start time.Time, err error, received int, sent int) {
+	code := status.Code(err)
+
+	event := log.Ctx(ctx).Info()
+	if code == codes.Internal || code == codes.Unknown {
+		event = log.Ctx(ctx).Error().Err(err)
+	}
+
+	if p, ok := peer.FromContext(ctx); ok {
+		event = event.Str("peer", p.Addr.String())
+	}
+
+	event.
+		Str("module", "access").
+		Str("code", code.String()).
+		Dur("duration", i.now().Sub(start)).
+		Int("request_bytes", received).
+		Int("response_bytes", sent).
+		Msg("Handled request")
+}
+
+// validRequestID accepts a caller request id of printable ascii only so it cannot forge the log lines
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+func messageSize(msg interface{}) int {
+	if m, ok := msg.(proto.Message); ok && m != nil {
+		return proto.Size(m)
+	}
+
+	return 0
+}
+
+// sizedServerStream overrides the context with the request scoped one and counts the message bytes
+type sizedServerStream struct {
+	grpc.ServerStream
+	ctx      context.Context
+	received int
+	sent     int
+}
+
+func (s *sizedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *sizedServerStream) RecvMsg(m interface{}) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.received += messageSize(m)
+	}
+
+	return err
+}
+
+func (s *sizedServerStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.sent += messageSize(m)
+	}
+
+	return err
+}
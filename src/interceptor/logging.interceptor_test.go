@@ -0,0 +1,103 @@
+package interceptor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"strings"
+	"testing"
+)
+
+type LoggingInterceptorTest struct {
+	suite.Suite
+	buf    *bytes.Buffer
+	logger zerolog.Logger
+	info   *grpc.UnaryServerInfo
+}
+
+func TestLoggingInterceptor(t *testing.T) {
+	suite.Run(t, new(LoggingInterceptorTest))
+}
+
+func (t *LoggingInterceptorTest) SetupTest() {
+	t.buf = &bytes.Buffer{}
+	t.logger = log.Logger
+	log.Logger = zerolog.New(t.buf)
+
+	t.info = &grpc.UnaryServerInfo{FullMethod: "/file.FileService/GetSignedUrl"}
+}
+
+func (t *LoggingInterceptorTest) TearDownTest() {
+	log.Logger = t.logger
+}
+
+func (t *LoggingInterceptorTest) call(md metadata.MD, handler grpc.UnaryHandler) error {
+	ctx := metadata.NewIncomingContext(context.Background(), md)
+	_, err := NewLoggingInterceptor().Unary()(ctx, nil, t.info, handler)
+
+	return err
+}
+
+func (t *LoggingInterceptorTest) lines() []map[string]interface{} {
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(t.buf.String()), "\n") {
+		entry := map[string]interface{}{}
+		assert.Nil(t.T(), json.Unmarshal([]byte(line), &entry))
+		lines = append(lines, entry)
+	}
+
+	return lines
+}
+
+func (t *LoggingInterceptorTest) TestPropagateRequestID() {
+	err := t.call(metadata.Pairs(RequestIDHeader, "req-1"), func(ctx context.Context, _ interface{}) (interface{}, error) {
+		log.Ctx(ctx).Info().Msg("in handler")
+		return nil, nil
+	})
+
+	assert.Nil(t.T(), err)
+
+	lines := t.lines()
+	assert.Len(t.T(), lines, 2)
+	for _, line := range lines {
+		assert.Equal(t.T(), "req-1", line["request_id"])
+		assert.Equal(t.T(), t.info.FullMethod, line["method"])
+	}
+	assert.Equal(t.T(), "access", lines[1]["module"])
+	assert.Equal(t.T(), "OK", lines[1]["code"])
+}
+
+func (t *LoggingInterceptorTest) TestAssignRequestID() {
+	for _, md := range []metadata.MD{{}, metadata.Pairs(RequestIDHeader, "forged\nline")} {
+		t.buf.Reset()
+
+		err := t.call(md, func(context.Context, interface{}) (interface{}, error) {
+			return nil, status.Error(codes.NotFound, "Not found file")
+		})
+
+		assert.Equal(t.T(), codes.NotFound, status.Code(err))
+
+		lines := t.lines()
+		assert.Len(t.T(), lines, 1)
+		assert.Len(t.T(), lines[0]["request_id"], 36)
+		assert.Equal(t.T(), "NotFound", lines[0]["code"])
+		assert.Equal(t.T(), "info", lines[0]["level"])
+	}
+}
+
+func (t *LoggingInterceptorTest) TestInternalLoggedAsError() {
+	err := t.call(metadata.MD{}, func(context.Context, interface{}) (interface{}, error) {
+		return nil, status.Error(codes.Internal, "Internal service error")
+	})
+
+	assert.Equal(t.T(), codes.Internal, status.Code(err))
+	assert.Equal(t.T(), "error", t.lines()[0]["level"])
+}
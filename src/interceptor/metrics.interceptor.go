@@ -0,0 +1,47 @@
+package interceptor
+
+import (
+	"context"
+	"github.com/isd-sgcu/rnkm65-file/src/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+	"time"
+)
+
+// MetricsInterceptor counts the requests and observes their latency by method and status code,
+// it has to come before the recovery and the auth interceptors so the panics and the rejected requests are counted too,
+// only the tracing interceptor runs before it so the span is already started
+type MetricsInterceptor struct {
+	now func() time.Time
+}
+
+func NewMetricsInterceptor() *MetricsInterceptor {
+	return &MetricsInterceptor{now: time.Now}
+}
+
+func (i *MetricsInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := i.now()
+		res, err := handler(ctx, req)
+		i.observe(info.FullMethod, start, err)
+
+		return res, err
+	}
+}
+
+func (i *MetricsInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := i.now()
+		err := handler(srv, ss)
+		i.observe(info.FullMethod, start, err)
+
+		return err
+	}
+}
+
+func (i *MetricsInterceptor) observe(method string, start time.Time, err error) {
+	code := status.Code(err).String()
+
+	metrics.RpcRequests.WithLabelValues(method, code).Inc()
+	metrics.RpcDuration.WithLabelValues(method, code).Observe(i.now().Sub(start).Seconds())
+}
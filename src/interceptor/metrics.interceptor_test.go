@@ -0,0 +1,49 @@
+package interceptor
+
+import (
+	"context"
+	"github.com/isd-sgcu/rnkm65-file/src/metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+type MetricsInterceptorTest struct {
+	suite.Suite
+	info *grpc.UnaryServerInfo
+}
+
+func TestMetricsInterceptor(t *testing.T) {
+	suite.Run(t, new(MetricsInterceptorTest))
+}
+
+func (t *MetricsInterceptorTest) SetupTest() {
+	t.info = &grpc.UnaryServerInfo{FullMethod: "/file.FileService/MetricsTest"}
+}
+
+func (t *MetricsInterceptorTest) TestCountsByCode() {
+	ok := metrics.RpcRequests.WithLabelValues(t.info.FullMethod, "OK")
+	notFound := metrics.RpcRequests.WithLabelValues(t.info.FullMethod, "NotFound")
+	before, beforeNotFound := testutil.ToFloat64(ok), testutil.ToFloat64(notFound)
+
+	interceptor := NewMetricsInterceptor().Unary()
+
+	res, err := interceptor(context.Background(), nil, t.info, func(context.Context, interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), "ok", res)
+
+	_, err = interceptor(context.Background(), nil, t.info, func(context.Context, interface{}) (interface{}, error) {
+		return nil, status.Error(codes.NotFound, "Not found file")
+	})
+
+	assert.Equal(t.T(), codes.NotFound, status.Code(err))
+	assert.Equal(t.T(), before+1, testutil.ToFloat64(ok))
+	assert.Equal(t.T(), beforeNotFound+1, testutil.ToFloat64(notFound))
+}
@@ -0,0 +1,56 @@
+package interceptor
+
+import (
+	"context"
+	"fmt"
+	"github.com/isd-sgcu/rnkm65-file/src/metrics"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"runtime/debug"
+)
+
+// RecoveryInterceptor turns a panic of the handler into codes.Internal so one bad request cannot take the
+// process down, it has to come after the logging interceptor so the panic is logged with the request id
+type RecoveryInterceptor struct{}
+
+func NewRecoveryInterceptor() *RecoveryInterceptor {
+	return &RecoveryInterceptor{}
+}
+
+func (i *RecoveryInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				res, err = nil, recovered(ctx, info.FullMethod, r)
+			}
+		}()
+
+		return handler(ctx, req)
+	}
+}
+
+func (i *RecoveryInterceptor) Stream() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = recovered(ss.Context(), info.FullMethod, r)
+			}
+		}()
+
+		return handler(srv, ss)
+	}
+}
+
+func recovered(ctx context.Context, method string, r interface{}) error {
+	metrics.RpcPanics.WithLabelValues(method).Inc()
+
+	log.Ctx(ctx).Error().
+		Str("module", "recovery").
+		Str("panic", fmt.Sprint(r)).
+		Bytes("stack", debug.Stack()).
+		Msg("Recovered from panic")
+
+	return status.Error(codes.Internal, "Internal service error")
+}
@@ -0,0 +1,53 @@
+package interceptor
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+)
+
+type RecoveryInterceptorTest struct {
+	suite.Suite
+}
+
+func TestRecoveryInterceptor(t *testing.T) {
+	suite.Run(t, new(RecoveryInterceptorTest))
+}
+
+func (t *RecoveryInterceptorTest) TestUnaryPanic() {
+	info := &grpc.UnaryServerInfo{FullMethod: "/file.FileService/Upload"}
+
+	res, err := NewRecoveryInterceptor().Unary()(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		var m map[string]string
+		m["boom"] = "nil map"
+		return "ok", nil
+	})
+
+	assert.Nil(t.T(), res)
+	assert.Equal(t.T(), codes.Internal, status.Code(err))
+}
+
+func (t *RecoveryInterceptorTest) TestUnaryNoPanic() {
+	info := &grpc.UnaryServerInfo{FullMethod: "/file.FileService/Upload"}
+
+	res, err := NewRecoveryInterceptor().Unary()(context.Background(), nil, info, func(context.Context, interface{}) (interface{}, error) {
+		return "ok", nil
+	})
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), "ok", res)
+}
+
+func (t *RecoveryInterceptorTest) TestStreamPanic() {
+	info := &grpc.StreamServerInfo{FullMethod: "/file.FileService/Stream"}
+
+	err := NewRecoveryInterceptor().Stream()(nil, &serverStream{ctx: context.Background()}, info, func(interface{}, grpc.ServerStream) error {
+		panic("boom")
+	})
+
+	assert.Equal(t.T(), codes.Internal, status.Code(err))
+}
@@ -0,0 +1,154 @@
+package lifecycle
+
+import (
+	"context"
+	"github.com/rs/zerolog/log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const defaultTimeout = 15 * time.Second
+
+// Hook cleans up one component, it should return once the context is done
+type Hook func(ctx context.Context) error
+
+type Stage struct {
+	name  string
+	names []string
+	hooks []Hook
+}
+
+// Add registers the hook, the hooks of the same stage run concurrently
+func (s *Stage) Add(name string, hook Hook) *Stage {
+	s.names = append(s.names, name)
+	s.hooks = append(s.hooks, hook)
+
+	return s
+}
+
+// Manager shuts the service down stage by stage, a stage starts once every hook of the previous one returned
+// so the clients are closed only after the requests and the background jobs using them are done
+type Manager struct {
+	timeout time.Duration
+	stages  []*Stage
+	signals chan os.Signal
+	exit    func(int)
+}
+
+// NewManager takes the deadline of the whole shutdown in seconds
+func NewManager(timeout int) *Manager {
+	m := &Manager{
+		timeout: defaultTimeout,
+		signals: make(chan os.Signal, 2),
+		exit:    os.Exit,
+	}
+
+	if timeout > 0 {
+		m.timeout = time.Duration(timeout) * time.Second
+	}
+
+	return m
+}
+
+// Stage appends a stage, the stages run in the order they were added
+func (m *Manager) Stage(name string) *Stage {
+	s := &Stage{name: name}
+	m.stages = append(m.stages, s)
+
+	return s
+}
+
+// Wait blocks until SIGINT or SIGTERM and shuts down, it returns the exit code, a second signal forces the exit
+func (m *Manager) Wait() int {
+	signal.Notify(m.signals, syscall.SIGINT, syscall.SIGTERM)
+
+	sig := <-m.signals
+	log.Info().
+		Str("service", "graceful shutdown").
+		Msgf("got signal \"%v\" shutting down service", sig)
+
+	go func() {
+		sig := <-m.signals
+		log.Error().
+			Str("service", "graceful shutdown").
+			Msgf("got signal \"%v\" again, force exit", sig)
+		m.exit(1)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), m.timeout)
+	defer cancel()
+
+	return m.Shutdown(ctx)
+}
+
+// Shutdown runs the stages in order until the context is done, it returns 1 when a hook failed or the deadline passed
+func (m *Manager) Shutdown(ctx context.Context) int {
+	code := 0
+
+	for _, stage := range m.stages {
+		done := make(chan bool, 1)
+		go func(stage *Stage) {
+			done <- m.run(ctx, stage)
+		}(stage)
+
+		select {
+		case ok := <-done:
+			if !ok {
+				code = 1
+			}
+		case <-ctx.Done():
+			log.Error().
+				Str("service", "graceful shutdown").
+				Str("stage", stage.name).
+				Msgf("timeout %v ms has been elapsed, force exit", m.timeout.Milliseconds())
+			return 1
+		}
+	}
+
+	return code
+}
+
+func (m *Manager) run(ctx context.Context, stage *Stage) bool {
+	log.Info().
+		Str("service", "graceful shutdown").
+		Str("stage", stage.name).
+		Msg("starting stage")
+
+	var wg sync.WaitGroup
+	ok := make([]bool, len(stage.hooks))
+
+	for i := range stage.hooks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			name := stage.names[i]
+			if err := stage.hooks[i](ctx); err != nil {
+				log.Error().
+					Err(err).
+					Str("service", "graceful shutdown").
+					Str("stage", stage.name).
+					Msgf("%v: clean up failed: %v", name, err.Error())
+				return
+			}
+
+			ok[i] = true
+			log.Info().
+				Str("service", "graceful shutdown").
+				Str("stage", stage.name).
+				Msgf("%v was shutdown gracefully", name)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, hookOk := range ok {
+		if !hookOk {
+			return false
+		}
+	}
+
+	return true
+}
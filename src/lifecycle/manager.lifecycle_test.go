@@ -0,0 +1,98 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type ManagerTest struct {
+	suite.Suite
+	mu    sync.Mutex
+	calls []string
+}
+
+func TestManager(t *testing.T) {
+	suite.Run(t, new(ManagerTest))
+}
+
+func (t *ManagerTest) SetupTest() {
+	t.calls = nil
+}
+
+func (t *ManagerTest) hook(name string, err error) Hook {
+	return func(context.Context) error {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		t.calls = append(t.calls, name)
+		return err
+	}
+}
+
+func (t *ManagerTest) TestStagesInOrder() {
+	m := NewManager(0)
+	m.Stage("drain").Add("server", func(ctx context.Context) error {
+		time.Sleep(20 * time.Millisecond)
+		return t.hook("server", nil)(ctx)
+	})
+	m.Stage("flush").Add("jobs", t.hook("jobs", nil))
+	m.Stage("close").Add("database", t.hook("database", nil)).Add("cache", t.hook("cache", nil))
+
+	code := m.Shutdown(context.Background())
+
+	assert.Equal(t.T(), 0, code)
+	assert.Equal(t.T(), []string{"server", "jobs"}, t.calls[:2])
+	assert.ElementsMatch(t.T(), []string{"database", "cache"}, t.calls[2:])
+}
+
+func (t *ManagerTest) TestHookFailedContinues() {
+	m := NewManager(0)
+	m.Stage("drain").Add("server", t.hook("server", errors.New("Something wrong :(")))
+	m.Stage("close").Add("database", t.hook("database", nil))
+
+	code := m.Shutdown(context.Background())
+
+	assert.Equal(t.T(), 1, code)
+	assert.Equal(t.T(), []string{"server", "database"}, t.calls)
+}
+
+func (t *ManagerTest) TestDeadlineForcesExit() {
+	m := NewManager(0)
+	m.Stage("drain").Add("server", func(context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	m.Stage("close").Add("database", t.hook("database", nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	code := m.Shutdown(ctx)
+
+	assert.Equal(t.T(), 1, code)
+	assert.Empty(t.T(), t.calls)
+}
+
+func (t *ManagerTest) TestSecondSignalForcesExit() {
+	exited := make(chan int, 1)
+	forced := -1
+
+	m := NewManager(0)
+	m.exit = func(code int) { exited <- code }
+	m.Stage("drain").Add("server", func(context.Context) error {
+		m.signals <- syscall.SIGTERM
+		forced = <-exited
+		return nil
+	})
+
+	m.signals <- syscall.SIGTERM
+	m.Wait()
+
+	assert.Equal(t.T(), 1, forced)
+}
@@ -3,83 +3,47 @@ package main
 import (
 	"context"
 	"fmt"
+	downloadHdr "github.com/isd-sgcu/rnkm65-file/src/app/handler/download"
+	healthHdr "github.com/isd-sgcu/rnkm65-file/src/app/handler/health"
+	linkHdr "github.com/isd-sgcu/rnkm65-file/src/app/handler/link"
+	aRepo "github.com/isd-sgcu/rnkm65-file/src/app/repository/audit"
 	"github.com/isd-sgcu/rnkm65-file/src/app/repository/cache"
 	fRepo "github.com/isd-sgcu/rnkm65-file/src/app/repository/file"
+	lRepo "github.com/isd-sgcu/rnkm65-file/src/app/repository/link"
+	oRepo "github.com/isd-sgcu/rnkm65-file/src/app/repository/outbox"
+	sRepo "github.com/isd-sgcu/rnkm65-file/src/app/repository/share"
+	wRepo "github.com/isd-sgcu/rnkm65-file/src/app/repository/webhook"
 	gcsSrv "github.com/isd-sgcu/rnkm65-file/src/app/service/gcs"
+	healthSrv "github.com/isd-sgcu/rnkm65-file/src/app/service/health"
+	outboxSrv "github.com/isd-sgcu/rnkm65-file/src/app/service/outbox"
+	webhookSrv "github.com/isd-sgcu/rnkm65-file/src/app/service/webhook"
+	"github.com/isd-sgcu/rnkm65-file/src/app/utils"
+	"github.com/isd-sgcu/rnkm65-file/src/client/bus"
 	gcsClt "github.com/isd-sgcu/rnkm65-file/src/client/gcs"
+	webhookClt "github.com/isd-sgcu/rnkm65-file/src/client/webhook"
 	"github.com/isd-sgcu/rnkm65-file/src/config"
 	"github.com/isd-sgcu/rnkm65-file/src/database"
+	"github.com/isd-sgcu/rnkm65-file/src/interceptor"
+	"github.com/isd-sgcu/rnkm65-file/src/lifecycle"
 	"github.com/isd-sgcu/rnkm65-file/src/proto"
+	"github.com/isd-sgcu/rnkm65-file/src/tracing"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/health"
 	"google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 	"net"
+	"net/http"
 	"os"
-	"os/signal"
-	"sync"
-	"syscall"
 	"time"
 )
 
-type operation func(ctx context.Context) error
-
-func gracefulShutdown(ctx context.Context, timeout time.Duration, ops map[string]operation) <-chan struct{} {
-	wait := make(chan struct{})
-	go func() {
-		s := make(chan os.Signal, 1)
-
-		signal.Notify(s, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
-		sig := <-s
-
-		log.Info().
-			Str("service", "graceful shutdown").
-			Msgf("got signal \"%v\" shutting down service", sig)
-
-		timeoutFunc := time.AfterFunc(timeout, func() {
-			log.Error().
-				Str("service", "graceful shutdown").
-				Msgf("timeout %v ms has been elapsed, force exit", timeout.Milliseconds())
-			os.Exit(0)
-		})
-
-		defer timeoutFunc.Stop()
-
-		var wg sync.WaitGroup
-
-		for key, op := range ops {
-			wg.Add(1)
-			innerOp := op
-			innerKey := key
-			go func() {
-				defer wg.Done()
-
-				log.Info().
-					Str("service", "graceful shutdown").
-					Msgf("cleaning up: %v", innerKey)
-				if err := innerOp(ctx); err != nil {
-					log.Error().
-						Str("service", "graceful shutdown").
-						Err(err).
-						Msgf("%v: clean up failed: %v", innerKey, err.Error())
-					return
-				}
-
-				log.Info().
-					Str("service", "graceful shutdown").
-					Msgf("%v was shutdown gracefully", innerKey)
-			}()
-		}
-
-		wg.Wait()
-		close(wait)
-	}()
-
-	return wait
-}
-
 func main() {
+	zerolog.DefaultContextLogger = &log.Logger
+
 	conf, err := config.LoadConfig()
 	if err != nil {
 		log.Fatal().
@@ -88,6 +52,14 @@ func main() {
 			Msg("Failed to start service")
 	}
 
+	shutdownTracing, err := tracing.Init(conf.Tracing)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Str("service", "file").
+			Msg("Failed to start service")
+	}
+
 	db, err := database.InitDatabase(&conf.Database)
 	if err != nil {
 		log.Fatal().
@@ -112,18 +84,126 @@ func main() {
 			Msg("Failed to start service")
 	}
 
-	cacheRepo := cache.NewRepository(cacheDB)
+	var lruRepo *cache.LRURepository
+	redisRepo := cache.NewRepository(cacheDB)
+	var cacheRepo gcsSrv.ICacheRepository = redisRepo
+	if conf.Cache.LocalSize > 0 {
+		cacheKeys := utils.NewCacheKey(conf.Cache.Prefix, conf.Cache.Version)
+		lruRepo = cache.NewLRURepository(redisRepo, conf.Cache.LocalSize, conf.Cache.LocalTTL, cacheKeys.Invalidation())
+		lruRepo.Start()
+		cacheRepo = lruRepo
+	}
+
+	fileRepo := fRepo.NewRepository(db, conf.Outbox.Enabled)
+	shareRepo := sRepo.NewRepository(db)
+	linkRepo := lRepo.NewRepository(db)
+	auditRepo := aRepo.NewBufferedRepository(aRepo.NewRepository(db), conf.Audit)
+	auditRepo.Start()
+
+	gcsClient, err := gcsClt.NewClient(conf.GCS)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Str("service", "file").
+			Msg("Failed to create google cloud storage client")
+	}
+
+	fileSrv := gcsSrv.NewService(conf.GCS, conf.App.CacheTTL, conf.Cache, conf.Auth, conf.Link, gcsClt.NewMetricsClient(gcsClient), fileRepo, shareRepo, linkRepo, cacheRepo, auditRepo)
+
+	metricsInterceptor := interceptor.NewMetricsInterceptor()
+	loggingInterceptor := interceptor.NewLoggingInterceptor()
+	recoveryInterceptor := interceptor.NewRecoveryInterceptor()
+	authInterceptor := interceptor.NewAuthInterceptor(conf.Auth)
+
+	jwtInterceptor, err := interceptor.NewJWTInterceptor(conf.Auth)
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Str("service", "file").
+			Msg("Failed to start service")
+	}
+
+	var webhookService *webhookSrv.Service
+	if conf.Webhook.Enabled {
+		if !conf.Outbox.Enabled {
+			log.Fatal().
+				Str("service", "file").
+				Msg("The webhooks need the outbox to be enabled")
+		}
 
-	fileRepo := fRepo.NewRepository(db)
+		webhookClient := webhookClt.NewClient(time.Duration(conf.Webhook.Timeout) * time.Second)
+		webhookService = webhookSrv.NewService(conf.Webhook, conf.Auth, wRepo.NewRepository(db), webhookClient)
+		webhookService.Start()
+	}
 
-	gcsClient := gcsClt.NewClient(conf.GCS)
-	fileSrv := gcsSrv.NewService(conf.GCS, conf.App.CacheTTL, gcsClient, fileRepo, cacheRepo)
+	var relay *outboxSrv.Relay
+	if conf.Outbox.Enabled {
+		var eventBus outboxSrv.IBus
+		switch conf.Outbox.Bus {
+		case "memory":
+			eventBus = bus.NewMemoryClient()
+		case "redis", "":
+			eventBus = bus.NewRedisClient(cacheDB, conf.Outbox.Stream, conf.Outbox.MaxLen)
+		default:
+			log.Fatal().
+				Str("service", "file").
+				Msgf("Unknown event bus %v", conf.Outbox.Bus)
+		}
+
+		if webhookService != nil {
+			eventBus = bus.NewFanoutClient(eventBus, webhookService)
+		}
+
+		relay = outboxSrv.NewRelay(conf.Outbox, oRepo.NewRepository(db), eventBus)
+		relay.Start()
+	}
 
-	grpcServer := grpc.NewServer(grpc.MaxRecvMsgSize(conf.App.MaxFileSize * 1024 * 1024))
+	grpcServer := grpc.NewServer(
+		grpc.MaxRecvMsgSize(conf.App.MaxFileSize*1024*1024),
+		grpc.ChainUnaryInterceptor(
+			otelgrpc.UnaryServerInterceptor(),
+			metricsInterceptor.Unary(),
+			loggingInterceptor.Unary(),
+			recoveryInterceptor.Unary(),
+			authInterceptor.Unary(),
+			jwtInterceptor.Unary(),
+		),
+		grpc.ChainStreamInterceptor(
+			otelgrpc.StreamServerInterceptor(),
+			metricsInterceptor.Stream(),
+			loggingInterceptor.Stream(),
+			recoveryInterceptor.Stream(),
+			authInterceptor.Stream(),
+			jwtInterceptor.Stream(),
+		),
+	)
 
-	grpc_health_v1.RegisterHealthServer(grpcServer, health.NewServer())
+	healthServer := health.NewServer()
+	grpc_health_v1.RegisterHealthServer(grpcServer, healthServer)
+
+	sqlDb, err := db.DB()
+	if err != nil {
+		log.Fatal().
+			Err(err).
+			Str("service", "file").
+			Msg("Failed to start service")
+	}
+
+	healthService := healthSrv.NewService(conf.Health, healthServer)
+	healthService.AddCheck("database", sqlDb.PingContext)
+	healthService.AddCheck("redis", func(ctx context.Context) error {
+		return cacheDB.Ping(ctx).Err()
+	})
+	healthService.AddCheck("storage", gcsClient.Ping)
 
 	proto.RegisterFileServiceServer(grpcServer, fileSrv)
+	healthService.AddService(proto.FileService_ServiceDesc.ServiceName, "database", "redis", "storage")
+	if webhookService != nil {
+		proto.RegisterWebhookServiceServer(grpcServer, webhookService)
+		healthService.AddService(proto.WebhookService_ServiceDesc.ServiceName, "database")
+	}
+
+	healthService.Start()
 
 	reflection.Register(grpcServer)
 	go func() {
@@ -139,31 +219,115 @@ func main() {
 		}
 	}()
 
-	wait := gracefulShutdown(context.Background(), 2*time.Second, map[string]operation{
-		"database": func(ctx context.Context) error {
-			sqlDb, err := db.DB()
-			if err != nil {
-				return err
+	mux := http.NewServeMux()
+	healthHandler := healthHdr.NewHandler(healthService)
+
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/livez", healthHandler.Live)
+	mux.HandleFunc("/readyz", healthHandler.Ready)
+	mux.Handle("/links/", linkHdr.NewHandler("/links/", fileSrv))
+	mux.Handle("/downloads/", downloadHdr.NewHandler("/downloads/", fileSrv))
+
+	httpServer := &http.Server{
+		Addr:    fmt.Sprintf(":%v", conf.App.HttpPort),
+		Handler: mux,
+	}
+
+	go func() {
+		log.Info().
+			Str("service", "file").
+			Msgf("RNKM65 file http server starting at port %v", conf.App.HttpPort)
+
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal().
+				Err(err).
+				Str("service", "file").
+				Msg("Failed to start http server")
+		}
+	}()
+
+	if conf.Cache.WarmSize > 0 {
+		fileSrv.StartWarmCache(context.Background(), conf.Cache.WarmSize)
+	}
+
+	manager := lifecycle.NewManager(conf.App.ShutdownTimeout)
+
+	manager.Stage("stop accepting").
+		Add("health", func(ctx context.Context) error {
+			healthService.Stop()
+			return nil
+		})
+
+	manager.Stage("drain requests").
+		Add("server", func(ctx context.Context) error {
+			stopped := make(chan struct{})
+			go func() {
+				grpcServer.GracefulStop()
+				close(stopped)
+			}()
+
+			select {
+			case <-stopped:
+				return nil
+			case <-ctx.Done():
+				grpcServer.Stop()
+				return ctx.Err()
+			}
+		}).
+		Add("http", func(ctx context.Context) error {
+			return httpServer.Shutdown(ctx)
+		})
+
+	manager.Stage("flush background jobs").
+		Add("jobs", func(ctx context.Context) error {
+			fileSrv.Wait()
+			return nil
+		}).
+		Add("audit", func(ctx context.Context) error {
+			auditRepo.Stop()
+			return nil
+		}).
+		Add("outbox", func(ctx context.Context) error {
+			if relay != nil {
+				relay.Stop()
 			}
-			return sqlDb.Close()
-		},
-		"server": func(ctx context.Context) error {
-			grpcServer.GracefulStop()
 			return nil
-		},
-		"cache": func(ctx context.Context) error {
+		}).
+		Add("webhook", func(ctx context.Context) error {
+			if webhookService != nil {
+				webhookService.Stop()
+			}
+			return nil
+		}).
+		Add("invalidation", func(ctx context.Context) error {
+			if lruRepo != nil {
+				lruRepo.Stop()
+			}
+			return nil
+		})
+
+	manager.Stage("close clients").
+		Add("database", func(ctx context.Context) error {
+			return sqlDb.Close()
+		}).
+		Add("cache", func(ctx context.Context) error {
 			return cacheDB.Close()
-		},
-	})
+		}).
+		Add("storage", func(ctx context.Context) error {
+			return gcsClient.Close()
+		}).
+		Add("tracing", func(ctx context.Context) error {
+			return shutdownTracing(ctx)
+		}).
+		Add("jwks", func(ctx context.Context) error {
+			jwtInterceptor.Close()
+			return nil
+		})
 
-	<-wait
+	code := manager.Wait()
 
-	grpcServer.GracefulStop()
-	log.Info().
-		Str("service", "file").
-		Msg("Closing the listener")
-	lis.Close()
 	log.Info().
 		Str("service", "file").
 		Msg("End of Program")
+	os.Exit(code)
 }
@@ -0,0 +1,39 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "rnkm65_file"
+
+// CacheCoalesced counts the cache misses that were answered by another in-flight lookup,
+// scope is "local" for the same process and "remote" for another replica holding the lock
+var CacheCoalesced = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "cache",
+	Name:      "coalesced_total",
+	Help:      "Number of signed url cache misses served by another in-flight lookup",
+}, []string{"scope"})
+
+var CacheNegativeHits = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "cache",
+	Name:      "negative_hits_total",
+	Help:      "Number of lookups answered by a cached not found entry",
+})
+
+var CacheRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "cache",
+	Name:      "refreshes_total",
+	Help:      "Number of signed urls re-signed ahead of their expiry",
+}, []string{"trigger"})
+
+// CacheLookups counts the signed url cache lookups, result is "hit", "miss" or "error"
+var CacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "cache",
+	Name:      "lookups_total",
+	Help:      "Number of signed url cache lookups by result",
+}, []string{"result"})
@@ -0,0 +1,28 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var RpcRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "grpc",
+	Name:      "requests_total",
+	Help:      "Number of handled grpc requests by method and status code",
+}, []string{"method", "code"})
+
+var RpcDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Subsystem: "grpc",
+	Name:      "request_duration_seconds",
+	Help:      "Latency of the handled grpc requests by method and status code",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"method", "code"})
+
+var RpcPanics = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "grpc",
+	Name:      "panics_total",
+	Help:      "Number of panics recovered from the grpc handlers by method",
+}, []string{"method"})
@@ -0,0 +1,23 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// StorageDuration is the latency of the google cloud storage calls, result is "ok" or "error"
+var StorageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Subsystem: "storage",
+	Name:      "operation_duration_seconds",
+	Help:      "Latency of the google cloud storage operations",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"operation", "result"})
+
+var UploadBytes = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Subsystem: "storage",
+	Name:      "upload_size_bytes",
+	Help:      "Size of the uploaded files by tag and type",
+	Buckets:   prometheus.ExponentialBuckets(1024, 4, 10),
+}, []string{"tag", "type"})
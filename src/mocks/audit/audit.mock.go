@@ -0,0 +1,27 @@
+package audit
+
+import (
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/audit"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/audit"
+	"github.com/stretchr/testify/mock"
+)
+
+type RepositoryMock struct {
+	mock.Mock
+}
+
+func (r *RepositoryMock) Create(in *audit.Audit) error {
+	args := r.Called(in.Action, in.UserID, in.FileID, in.Code)
+
+	return args.Error(0)
+}
+
+func (r *RepositoryMock) Find(filter *dto.Filter, in *[]audit.Audit) error {
+	args := r.Called(filter)
+
+	if args.Get(0) != nil {
+		*in = args.Get(0).([]audit.Audit)
+	}
+
+	return args.Error(1)
+}
@@ -0,0 +1,17 @@
+package bus
+
+import (
+	"context"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/event"
+	"github.com/stretchr/testify/mock"
+)
+
+type ClientMock struct {
+	mock.Mock
+}
+
+func (c *ClientMock) Publish(_ context.Context, msg *dto.Message) error {
+	args := c.Called(msg)
+
+	return args.Error(0)
+}
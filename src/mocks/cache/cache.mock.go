@@ -1,8 +1,10 @@
 package cache
 
 import (
+	"context"
 	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
 	"github.com/stretchr/testify/mock"
+	"reflect"
 )
 
 type RepositoryMock struct {
@@ -10,20 +12,70 @@ type RepositoryMock struct {
 	V map[string]interface{}
 }
 
-func (t *RepositoryMock) SaveCache(key string, v interface{}, ttl int) error {
-	args := t.Called(key, v.(*dto.CacheFile).Url, ttl)
+func (t *RepositoryMock) SaveCache(_ context.Context, key string, value interface{}, ttl int) error {
+	v := value
+	if cacheFile, ok := v.(*dto.CacheFile); ok {
+		v = cacheFile.Url
+	}
+	args := t.Called(key, v, ttl)
 
-	t.V[key] = v
+	t.V[key] = value
 
 	return args.Error(0)
 }
 
-func (t *RepositoryMock) GetCache(key string, v interface{}) error {
+func (t *RepositoryMock) GetCache(_ context.Context, key string, v interface{}) error {
 	args := t.Called(key, v)
 
 	if args.Get(0) != nil {
-		*v.(*dto.CacheFile) = *args.Get(0).(*dto.CacheFile)
+		reflect.ValueOf(v).Elem().Set(reflect.ValueOf(args.Get(0)).Elem())
 	}
 
 	return args.Error(1)
 }
+
+func (t *RepositoryMock) GetManyCache(_ context.Context, keys []string, v []interface{}) ([]bool, error) {
+	args := t.Called(keys)
+
+	found := make([]bool, len(keys))
+	if args.Get(0) != nil {
+		for i, cached := range args.Get(0).([]*dto.CacheFile) {
+			if cached != nil {
+				*v[i].(*dto.CacheFile) = *cached
+				found[i] = true
+			}
+		}
+	}
+
+	return found, args.Error(1)
+}
+
+func (t *RepositoryMock) Increment(_ context.Context, key string, ttl int) (int64, error) {
+	args := t.Called(key, ttl)
+
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (t *RepositoryMock) AcquireLock(_ context.Context, key string, ttl int) (string, error) {
+	args := t.Called(key, ttl)
+
+	return args.String(0), args.Error(1)
+}
+
+func (t *RepositoryMock) ReleaseLock(_ context.Context, key string, token string) error {
+	args := t.Called(key, token)
+
+	return args.Error(0)
+}
+
+func (t *RepositoryMock) DeleteCache(_ context.Context, keys ...string) (int64, error) {
+	args := t.Called(keys)
+
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (t *RepositoryMock) DeleteCacheByPattern(_ context.Context, pattern string) (int64, error) {
+	args := t.Called(pattern)
+
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -1,6 +1,7 @@
 package file
 
 import (
+	"context"
 	"github.com/isd-sgcu/rnkm65-file/src/app/model/file"
 	"github.com/stretchr/testify/mock"
 )
@@ -9,8 +10,8 @@ type RepositoryMock struct {
 	mock.Mock
 }
 
-func (r *RepositoryMock) FindByOwnerID(id string, in *file.File) error {
-	args := r.Called(id, in)
+func (r *RepositoryMock) FindByID(_ context.Context, id string, in *file.File) error {
+	args := r.Called(id)
 
 	if args.Get(0) != nil {
 		*in = *args.Get(0).(*file.File)
@@ -19,8 +20,8 @@ func (r *RepositoryMock) FindByOwnerID(id string, in *file.File) error {
 	return args.Error(1)
 }
 
-func (r *RepositoryMock) CreateOrUpdate(in *file.File) error {
-	args := r.Called(in.OwnerID)
+func (r *RepositoryMock) FindByOwnerID(_ context.Context, id string, in *file.File) error {
+	args := r.Called(id, in)
 
 	if args.Get(0) != nil {
 		*in = *args.Get(0).(*file.File)
@@ -29,8 +30,53 @@ func (r *RepositoryMock) CreateOrUpdate(in *file.File) error {
 	return args.Error(1)
 }
 
-func (r *RepositoryMock) Delete(id string) error {
-	args := r.Called(id)
+func (r *RepositoryMock) FindByOwnerIDs(_ context.Context, ids []string, in *[]file.File) error {
+	args := r.Called(ids)
+
+	if args.Get(0) != nil {
+		*in = args.Get(0).([]file.File)
+	}
+
+	return args.Error(1)
+}
+
+func (r *RepositoryMock) FindByTag(_ context.Context, tag int, in *[]file.File) error {
+	args := r.Called(tag)
+
+	if args.Get(0) != nil {
+		*in = args.Get(0).([]file.File)
+	}
+
+	return args.Error(1)
+}
+
+func (r *RepositoryMock) FindRecent(_ context.Context, limit int, in *[]file.File) error {
+	args := r.Called(limit)
+
+	if args.Get(0) != nil {
+		*in = args.Get(0).([]file.File)
+	}
+
+	return args.Error(1)
+}
+
+func (r *RepositoryMock) CreateOrUpdate(_ context.Context, in *file.File, version int64) (*file.File, error) {
+	args := r.Called(in.OwnerID, version)
+
+	if args.Get(0) != nil {
+		*in = *args.Get(0).(*file.File)
+	}
+
+	var previous *file.File
+	if args.Get(1) != nil {
+		previous = args.Get(1).(*file.File)
+	}
+
+	return previous, args.Error(2)
+}
+
+func (r *RepositoryMock) Delete(_ context.Context, id string, version int64) error {
+	args := r.Called(id, version)
 
 	return args.Error(0)
 }
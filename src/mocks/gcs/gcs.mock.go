@@ -1,21 +1,52 @@
 package gcs
 
 import (
+	"context"
+	dto "github.com/isd-sgcu/rnkm65-file/src/app/dto/file"
 	"github.com/stretchr/testify/mock"
+	"time"
 )
 
 type ClientMock struct {
 	mock.Mock
 }
 
-func (c *ClientMock) Upload(file []byte, _ string) error {
+func (c *ClientMock) Upload(_ context.Context, file []byte, _ string) error {
 	args := c.Called(file)
 
 	return args.Error(0)
 }
 
-func (c *ClientMock) GetSignedUrl(_ string) (string, error) {
+func (c *ClientMock) UploadPublic(_ context.Context, file []byte, filename string) error {
+	args := c.Called(file, filename)
+
+	return args.Error(0)
+}
+
+func (c *ClientMock) GetPublicUrl(_ string) string {
 	args := c.Called()
 
+	return args.String(0)
+}
+
+func (c *ClientMock) GetSignedUrl(_ context.Context, _ string, expiresIn time.Duration) (string, error) {
+	args := c.Called(expiresIn)
+
 	return args.String(0), args.Error(1)
 }
+
+func (c *ClientMock) Delete(_ context.Context, filename string, public bool) error {
+	args := c.Called(filename, public)
+
+	return args.Error(0)
+}
+
+func (c *ClientMock) Open(_ context.Context, filename string, public bool) (*dto.Object, error) {
+	args := c.Called(filename, public)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*dto.Object), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
@@ -0,0 +1,29 @@
+package health
+
+import (
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"sync"
+)
+
+type ServerMock struct {
+	mu         sync.Mutex
+	Statuses   map[string]grpc_health_v1.HealthCheckResponse_ServingStatus
+	IsShutdown bool
+}
+
+func (s *ServerMock) SetServingStatus(service string, status grpc_health_v1.HealthCheckResponse_ServingStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Statuses == nil {
+		s.Statuses = map[string]grpc_health_v1.HealthCheckResponse_ServingStatus{}
+	}
+	s.Statuses[service] = status
+}
+
+func (s *ServerMock) Shutdown() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.IsShutdown = true
+}
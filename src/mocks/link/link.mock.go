@@ -0,0 +1,46 @@
+package link
+
+import (
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/link"
+	"github.com/stretchr/testify/mock"
+)
+
+type RepositoryMock struct {
+	mock.Mock
+}
+
+func (r *RepositoryMock) FindByID(id string, in *link.Link) error {
+	args := r.Called(id)
+
+	if args.Get(0) != nil {
+		*in = *args.Get(0).(*link.Link)
+	}
+
+	return args.Error(1)
+}
+
+func (r *RepositoryMock) FindByTokenHash(hash string, in *link.Link) error {
+	args := r.Called(hash)
+
+	if args.Get(0) != nil {
+		*in = *args.Get(0).(*link.Link)
+	}
+
+	return args.Error(1)
+}
+
+func (r *RepositoryMock) Create(in *link.Link) error {
+	args := r.Called(in.FileID, in.MaxDownloads)
+
+	if args.Get(0) != nil {
+		*in = *args.Get(0).(*link.Link)
+	}
+
+	return args.Error(1)
+}
+
+func (r *RepositoryMock) Delete(id string) error {
+	args := r.Called(id)
+
+	return args.Error(0)
+}
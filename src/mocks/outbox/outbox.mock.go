@@ -0,0 +1,37 @@
+package outbox
+
+import (
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/outbox"
+	"github.com/stretchr/testify/mock"
+	"time"
+)
+
+type RepositoryMock struct {
+	mock.Mock
+}
+
+// Relay publishes the events given to On in order like the repository does and returns how many were published
+func (r *RepositoryMock) Relay(limit int, lease time.Duration, publish func(*outbox.Event) error) (int, error) {
+	args := r.Called(limit, lease)
+
+	if args.Error(1) != nil {
+		return 0, args.Error(1)
+	}
+
+	published := 0
+	for _, e := range args.Get(0).([]outbox.Event) {
+		e := e
+		if err := publish(&e); err != nil {
+			return published, err
+		}
+		published++
+	}
+
+	return published, nil
+}
+
+func (r *RepositoryMock) DeletePublished(before time.Time) (int64, error) {
+	args := r.Called(before)
+
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -0,0 +1,46 @@
+package share
+
+import (
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/share"
+	"github.com/stretchr/testify/mock"
+)
+
+type RepositoryMock struct {
+	mock.Mock
+}
+
+func (r *RepositoryMock) CreateOrUpdate(in *share.Share) error {
+	args := r.Called(in.OwnerID, in.GranteeType, in.GranteeID)
+
+	if args.Get(0) != nil {
+		*in = *args.Get(0).(*share.Share)
+	}
+
+	return args.Error(1)
+}
+
+func (r *RepositoryMock) Delete(ownerID string, granteeType string, granteeID string) error {
+	args := r.Called(ownerID, granteeType, granteeID)
+
+	return args.Error(0)
+}
+
+func (r *RepositoryMock) FindSharedWith(userID string, groups []string, in *[]share.Share) error {
+	args := r.Called(userID, groups)
+
+	if args.Get(0) != nil {
+		*in = args.Get(0).([]share.Share)
+	}
+
+	return args.Error(1)
+}
+
+func (r *RepositoryMock) FindGranted(ownerIDs []string, userID string, groups []string, in *[]share.Share) error {
+	args := r.Called(ownerIDs, userID, groups)
+
+	if args.Get(0) != nil {
+		*in = args.Get(0).([]share.Share)
+	}
+
+	return args.Error(1)
+}
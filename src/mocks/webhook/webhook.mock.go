@@ -0,0 +1,106 @@
+package webhook
+
+import (
+	"context"
+	"github.com/isd-sgcu/rnkm65-file/src/app/model/webhook"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"time"
+)
+
+type RepositoryMock struct {
+	mock.Mock
+}
+
+func (r *RepositoryMock) FindSubscriptions(in *[]webhook.Subscription) error {
+	args := r.Called()
+
+	if args.Get(0) != nil {
+		*in = args.Get(0).([]webhook.Subscription)
+	}
+
+	return args.Error(1)
+}
+
+func (r *RepositoryMock) FindSubscriptionByID(id string, in *webhook.Subscription) error {
+	args := r.Called(id)
+
+	if args.Get(0) != nil {
+		*in = *args.Get(0).(*webhook.Subscription)
+	}
+
+	return args.Error(1)
+}
+
+func (r *RepositoryMock) CreateSubscription(in *webhook.Subscription) error {
+	args := r.Called(in.Url, in.Events)
+
+	if args.Get(0) != nil {
+		*in = *args.Get(0).(*webhook.Subscription)
+	}
+
+	return args.Error(1)
+}
+
+func (r *RepositoryMock) DeleteSubscription(id string) error {
+	args := r.Called(id)
+
+	return args.Error(0)
+}
+
+func (r *RepositoryMock) CreateDeliveries(in []webhook.Delivery) error {
+	args := r.Called(in)
+
+	return args.Error(0)
+}
+
+func (r *RepositoryMock) ClaimDeliveries(limit int, lease time.Duration, in *[]webhook.Delivery) error {
+	args := r.Called(limit)
+
+	if args.Get(0) != nil {
+		*in = args.Get(0).([]webhook.Delivery)
+	}
+
+	return args.Error(1)
+}
+
+func (r *RepositoryMock) UpdateDelivery(in *webhook.Delivery) error {
+	args := r.Called(in.ID.String(), in.Status, in.Attempts)
+
+	return args.Error(0)
+}
+
+func (r *RepositoryMock) FindDeliveries(subscriptionId string, status string, limit int, in *[]webhook.Delivery) error {
+	args := r.Called(subscriptionId, status, limit)
+
+	if args.Get(0) != nil {
+		*in = args.Get(0).([]webhook.Delivery)
+	}
+
+	return args.Error(1)
+}
+
+func (r *RepositoryMock) RetryDelivery(id string, in *webhook.Delivery) error {
+	args := r.Called(id)
+
+	if args.Get(0) != nil {
+		*in = *args.Get(0).(*webhook.Delivery)
+	}
+
+	return args.Error(1)
+}
+
+type ClientMock struct {
+	mock.Mock
+	Header http.Header
+	Body   []byte
+}
+
+func (c *ClientMock) Post(_ context.Context, url string, header http.Header, body []byte) (int, error) {
+	args := c.Called(url)
+
+	c.Header = header
+	c.Body = body
+
+	return args.Int(0), args.Error(1)
+}
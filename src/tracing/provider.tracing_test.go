@@ -0,0 +1,52 @@
+package tracing
+
+import (
+	"context"
+	"github.com/isd-sgcu/rnkm65-file/src/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+type ProviderTest struct {
+	suite.Suite
+}
+
+func TestProvider(t *testing.T) {
+	suite.Run(t, new(ProviderTest))
+}
+
+func (t *ProviderTest) TestNonePropagatesIncomingTrace() {
+	shutdown, err := Init(config.Tracing{Exporter: "none"})
+
+	assert.Nil(t.T(), err)
+	assert.Nil(t.T(), shutdown(context.Background()))
+
+	carrier := propagation.MapCarrier{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), carrier)
+
+	assert.Equal(t.T(), "4bf92f3577b34da6a3ce929d0e0e4736", trace.SpanContextFromContext(ctx).TraceID().String())
+}
+
+func (t *ProviderTest) TestStdout() {
+	shutdown, err := Init(config.Tracing{Exporter: "stdout", SampleRatio: 0.5})
+
+	assert.Nil(t.T(), err)
+	assert.Nil(t.T(), shutdown(context.Background()))
+}
+
+func (t *ProviderTest) TestUnknownExporter() {
+	shutdown, err := Init(config.Tracing{Exporter: "zipkin"})
+
+	assert.NotNil(t.T(), err)
+	assert.Nil(t.T(), shutdown)
+}
+
+func (t *ProviderTest) TestSampleRatio() {
+	assert.Equal(t.T(), 1.0, sampleRatio(0))
+	assert.Equal(t.T(), 1.0, sampleRatio(2))
+	assert.Equal(t.T(), 0.25, sampleRatio(0.25))
+}